@@ -0,0 +1,89 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+)
+
+//SignRequest signs an attempt. It is called before every attempt, so signatures that
+//embed a timestamp are fresh for retries. body is the buffered request body, nil for
+//bodies that are streamed (see UploadMultipart).
+type SignRequest func(req *http.Request, body []byte) error
+
+//PrepareRetry is called before every retry (attempt 1 and later) with the request of the
+//attempt, e.g. to refresh timestamps or rotate nonces. An error aborts the retries.
+type PrepareRetry func(req *http.Request, attempt int) error
+
+//prepareError is the error of an attempt that could not be prepared and is not retried.
+type prepareError struct {
+	err error
+}
+
+func (e prepareError) Error() string {
+	return e.err.Error()
+}
+
+func (e prepareError) Unwrap() error {
+	return e.err
+}
+
+//addDefaultHeaders adds the DefaultHeaders and the UserAgent unless the header sets them.
+func (c *FailAwareHTTPClient) addDefaultHeaders(header http.Header) {
+	for name, values := range c.options().DefaultHeaders {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; !ok {
+			header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	if c.options().UserAgent != "" && header.Get("User-Agent") == "" {
+		header.Set("User-Agent", c.options().UserAgent)
+	}
+}
+
+//expectContinue asks the server to confirm the attempt before its body is sent, if the body
+//is larger than the ExpectContinueThreshold. bufferedSize is the size of a buffered body.
+func (c *FailAwareHTTPClient) expectContinue(req *http.Request, bufferedSize int64) {
+	size := bufferedSize
+	if req.ContentLength > size {
+		//streamed bodies are not buffered
+		size = req.ContentLength
+	}
+	if c.options().ExpectContinueThreshold > 0 && size > c.options().ExpectContinueThreshold {
+		req.Header.Set("Expect", "100-continue")
+	}
+}
+
+//sendAttempt prepares a retry, adds the token of the TokenSource to the attempt, signs, logs
+//(see RequestLogHook) and sends it and decodes the response. A failure to get the token or to
+//decode the response fails the attempt, a failure to prepare or sign it fails the request.
+func (c *FailAwareHTTPClient) sendAttempt(req *http.Request, body []byte, attempt int, refreshToken bool) (*http.Response, error) {
+	if attempt > 0 && c.options().PrepareRetry != nil {
+		if err := c.options().PrepareRetry(req, attempt); err != nil {
+			return nil, prepareError{err: fmt.Errorf("preparing retry: %w", err)}
+		}
+	}
+	if c.options().TokenSource != nil {
+		token, err := c.options().TokenSource.Token(refreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("fetching token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	//signed like every other header
+	decode := c.acceptEncodings(req)
+	if c.options().SignRequest != nil {
+		if err := c.options().SignRequest(req, body); err != nil {
+			return nil, prepareError{err: fmt.Errorf("signing request: %w", err)}
+		}
+	}
+	if c.options().RequestLogHook != nil {
+		c.options().RequestLogHook(c.options().Logger, redactRequest(req, c.redactHeaders), attempt)
+	}
+	if !decode {
+		return c.send(req)
+	}
+	rsp, err := c.send(req)
+	if err != nil {
+		return rsp, err
+	}
+	return c.decodeResponse(rsp)
+}
@@ -0,0 +1,221 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignRequestPerAttempt(t *testing.T) {
+	var signatures []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+		w.WriteHeader(500)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	signed := 0
+	opts := optionsWithMinTimeouts()
+	opts.SignRequest = func(req *http.Request, body []byte) error {
+		signed++
+		assert.Equal(t, "payload", string(body))
+		req.Header.Set("X-Signature", strconv.Itoa(signed))
+		return nil
+	}
+	client := NewClient(opts)
+
+	rsp, err := client.Post(fmt.Sprintf("http://localhost:%d", port), "text/plain", strings.NewReader("payload"))
+	assert.Nil(t, err)
+	assert.Equal(t, 500, rsp.StatusCode)
+	assert.Equal(t, []string{"1", "2", "3"}, signatures)
+}
+
+func TestSignRequestErrorAborts(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	signErr := errors.New("no credentials")
+	opts := optionsWithMinTimeouts()
+	opts.SignRequest = func(req *http.Request, body []byte) error {
+		return signErr
+	}
+
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindPrepareFailed, failErr.Kind)
+	assert.Equal(t, 0, failErr.Retries)
+	assert.True(t, errors.Is(failErr.LastError, signErr))
+}
+
+func TestPrepareRetry(t *testing.T) {
+	var nonces []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, r.Header.Get("X-Nonce"))
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	var attempts []int
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be retried once more
+	opts.Timeout = time.Second
+	opts.PrepareRetry = func(req *http.Request, attempt int) error {
+		attempts = append(attempts, attempt)
+		req.Header.Set("X-Nonce", fmt.Sprintf("nonce-%d", attempt))
+		return nil
+	}
+
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.Equal(t, []string{"", "nonce-1", "nonce-2"}, nonces)
+}
+
+func TestPrepareRetryErrorAborts(t *testing.T) {
+	calls := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.PrepareRetry = func(req *http.Request, attempt int) error {
+		return errors.New("nonce service down")
+	}
+
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindPrepareFailed, failErr.Kind)
+	assert.Equal(t, 1, failErr.Retries)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryAttemptHeader(t *testing.T) {
+	var attempts []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, r.Header.Get("X-Retry-Attempt"))
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.RetryAttemptHeader = "X-Retry-Attempt"
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"", "1", "2"}, attempts)
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	var headers []http.Header
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.DefaultHeaders = http.Header{"X-Api-Key": {"secret"}, "Accept": {"application/json"}}
+	opts.UserAgent = "inventory-service/1.2"
+	client := NewClient(opts)
+
+	_, err = client.Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	req.Header.Set("Accept", "text/plain")
+	req.Header.Set("User-Agent", "custom")
+	_, err = client.Do(req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "secret", headers[0].Get("X-Api-Key"))
+	assert.Equal(t, "application/json", headers[0].Get("Accept"))
+	assert.Equal(t, "inventory-service/1.2", headers[0].Get("User-Agent"))
+	assert.Equal(t, "secret", headers[1].Get("X-Api-Key"))
+	assert.Equal(t, "text/plain", headers[1].Get("Accept"))
+	assert.Equal(t, "custom", headers[1].Get("User-Agent"))
+	assert.Empty(t, req.Header.Get("X-Api-Key"), "request of the caller unchanged")
+}
+
+//countingConn counts the bytes received by a server.
+type countingConn struct {
+	net.Conn
+	received *int64
+}
+
+func (c countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(c.received, int64(n))
+	return n, err
+}
+
+type countingListener struct {
+	net.Listener
+	received *int64
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	return countingConn{Conn: conn, received: l.received}, err
+}
+
+func TestExpectContinue(t *testing.T) {
+	var received int64
+	var expects []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expects = append(expects, r.Header.Get("Expect"))
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(401)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, len(body))
+	}))
+	server.Listener = countingListener{Listener: server.Listener, received: &received}
+	server.Start()
+	defer server.Close()
+
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = 5 * time.Second
+	opts.ExpectContinueThreshold = 1000
+	client := NewClient(opts)
+	large := strings.Repeat("x", 100000)
+
+	rsp, err := client.Post(server.URL+"/reject", "text/plain", strings.NewReader(large))
+	assert.Nil(t, err)
+	assert.Equal(t, 401, rsp.StatusCode)
+	assert.True(t, atomic.LoadInt64(&received) < 10000, "the body was not transmitted")
+
+	rsp, err = client.Post(server.URL+"/accept", "text/plain", strings.NewReader(large))
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(rsp.Body)
+	assert.Equal(t, "100000", string(body))
+
+	_, err = client.Post(server.URL+"/accept", "text/plain", strings.NewReader("small"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"100-continue", "100-continue", ""}, expects)
+}
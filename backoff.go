@@ -0,0 +1,145 @@
+package http
+
+import (
+	"math"
+	"time"
+)
+
+//BackOffStrategy selects how the back off grows with the retries.
+type BackOffStrategy int
+
+const (
+	//BackOffExponential doubles the back off with every retry: factor * 2^retries.
+	BackOffExponential BackOffStrategy = iota
+	//BackOffConstant waits the factor before every retry.
+	BackOffConstant
+	//BackOffLinear grows the back off by the factor with every retry: factor * (retries+1).
+	BackOffLinear
+	//BackOffFibonacci grows the back off with the Fibonacci numbers: factor * 1, 1, 2, 3, 5, 8...
+	BackOffFibonacci
+)
+
+//JitterMode selects how the back off is randomized.
+type JitterMode int
+
+const (
+	//JitterRelative varies the back off by up to a third in both directions.
+	JitterRelative JitterMode = iota
+	//JitterFull waits a random time between 0 and the back off ("full jitter"), which
+	//spreads the retries of clients that failed at the same time further apart.
+	JitterFull
+)
+
+//maxBackOffExponent keeps the exponential back off from overflowing.
+const maxBackOffExponent = 30
+
+//maxBackOff is the longest back off, a longer one would overflow the Duration.
+const maxBackOff = time.Duration(math.MaxInt64)
+
+//backOff returns the time to wait before the retry following the given number of retries.
+func (c *FailAwareHTTPClient) backOff(policy RetryPolicy, retries int) time.Duration {
+	if policy.RetryImmediately {
+		if retries == 0 {
+			return 0
+		}
+		retries--
+	}
+	//capped before the jitter too, which then randomizes the capped back off
+	wait := policy.capBackOff(baseBackOff(policy.BackOffStrategy, retries, policy.BackOffDelayFactor))
+	switch {
+	case policy.DisableJitter:
+	case policy.Jitter == JitterFull:
+		wait = fullJitter(c.random, wait)
+	default:
+		wait = relativeJitter(c.random, wait)
+	}
+	return policy.capBackOff(wait)
+}
+
+//baseBackOff returns the back off of the strategy, without jitter.
+func baseBackOff(strategy BackOffStrategy, retries int, backOffDelayFactor time.Duration) time.Duration {
+	var wait time.Duration
+	switch strategy {
+	case BackOffConstant:
+		wait = backOffDelayFactor
+	case BackOffLinear:
+		wait = scaleBackOff(retries+1, backOffDelayFactor)
+	case BackOffFibonacci:
+		wait = scaleBackOff(fibonacci(retries+1), backOffDelayFactor)
+	default:
+		return expBackOff(retries, backOffDelayFactor)
+	}
+	if wait < time.Millisecond {
+		return time.Millisecond
+	}
+	return wait.Truncate(time.Millisecond)
+}
+
+//capBackOff limits a wait to MaxBackOff, a negative wait has overflowed and is the longest one.
+func (p RetryPolicy) capBackOff(wait time.Duration) time.Duration {
+	if wait < 0 {
+		wait = maxBackOff
+	}
+	if p.MaxBackOff > 0 && wait > p.MaxBackOff {
+		return p.MaxBackOff
+	}
+	return wait
+}
+
+//fibonacci returns the n-th Fibonacci number, n is limited like the exponent of the back off.
+func fibonacci(n int) int {
+	if n > maxBackOffExponent {
+		n = maxBackOffExponent
+	}
+	previous, current := 0, 1
+	for i := 1; i < n; i++ {
+		previous, current = current, previous+current
+	}
+	return current
+}
+
+func backOffExponent(retries int) int {
+	if retries > maxBackOffExponent {
+		retries = maxBackOffExponent
+	}
+	return int(1 << uint(retries))
+}
+
+func expBackOff(retries int, backOffDelayFactor time.Duration) time.Duration {
+	wait := scaleBackOff(backOffExponent(retries), backOffDelayFactor.Truncate(time.Millisecond))
+	if wait <= 0 {
+		return time.Millisecond
+	}
+	return wait.Truncate(time.Millisecond)
+}
+
+//scaleBackOff multiplies the factor, a product past the range of a Duration is the longest back off.
+func scaleBackOff(n int, backOffDelayFactor time.Duration) time.Duration {
+	if n > 0 && backOffDelayFactor > maxBackOff/time.Duration(n) {
+		return maxBackOff
+	}
+	return time.Duration(n) * backOffDelayFactor
+}
+
+//relativeJitter varies the back off by up to a third in both directions.
+func relativeJitter(random *lockedRand, backOff time.Duration) time.Duration {
+	ms := int(backOff / time.Millisecond)
+	maxJitter := ms / 3
+	// ms ± rand
+	if maxJitter > 0 {
+		ms += random.Intn(2*maxJitter) - maxJitter
+	}
+	if ms <= 0 {
+		ms = 1
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+//fullJitter returns a random wait between 0 and the back off.
+func fullJitter(random *lockedRand, backOff time.Duration) time.Duration {
+	ms := int(backOff / time.Millisecond)
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(random.Intn(ms+1)) * time.Millisecond
+}
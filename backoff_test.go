@@ -0,0 +1,157 @@
+package http
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableJitterWaitsExactly(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.DisableJitter = true
+	client := NewClient(opts)
+	_, err := client.Post(nonExistingURL, "application/json", strings.NewReader("dummyBody"))
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}, clock.waits, "no back off after the last attempt")
+}
+
+func TestJitterStaysWithinBounds(t *testing.T) {
+	random := newLockedRand(nil)
+	for retries := 0; retries < 5; retries++ {
+		base := expBackOff(retries, 30*time.Millisecond)
+		for i := 0; i < 100; i++ {
+			wait := relativeJitter(random, base)
+			assert.True(t, wait >= base-base/3 && wait <= base+base/3, wait)
+		}
+	}
+}
+
+func TestMaxBackOff(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.DisableJitter = true
+	opts.MaxRetries = 5
+	opts.MaxBackOff = 15 * time.Millisecond
+	client := NewClient(opts)
+	_, err := client.Get(nonExistingURL)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 15 * time.Millisecond,
+		15 * time.Millisecond}, clock.waits)
+}
+
+func TestBackOffDoesNotOverflow(t *testing.T) {
+	policy := RetryPolicy{BackOffDelayFactor: time.Second, DisableJitter: true, MaxBackOff: time.Minute}
+	client := NewClient(optionsWithMinTimeouts())
+	assert.Equal(t, time.Minute, client.backOff(policy, 100))
+
+	assert.True(t, expBackOff(100, 10*time.Second) > 0)
+	policy = RetryPolicy{BackOffDelayFactor: 10 * time.Second, MaxBackOff: 5 * time.Minute, Jitter: JitterFull}
+	for retries := 29; retries < 40; retries++ {
+		wait := client.backOff(policy, retries)
+		assert.True(t, wait >= 0 && wait <= 5*time.Minute, wait)
+	}
+	assert.Equal(t, time.Duration(0), fullJitter(newLockedRand(nil), 0))
+}
+
+func TestMaxBackOffCapsOverflowingWaits(t *testing.T) {
+	client := NewClient(optionsWithMinTimeouts())
+	for _, strategy := range []BackOffStrategy{BackOffExponential, BackOffLinear, BackOffFibonacci} {
+		policy := RetryPolicy{BackOffStrategy: strategy, BackOffDelayFactor: 1 << 50, MaxBackOff: 5 * time.Minute}
+		for retries := 25; retries < 40; retries++ {
+			policy.DisableJitter = true
+			assert.Equal(t, 5*time.Minute, client.backOff(policy, retries), strategy)
+			policy.DisableJitter = false
+			wait := client.backOff(policy, retries)
+			assert.True(t, wait >= 3*time.Minute && wait <= 5*time.Minute, wait)
+		}
+	}
+	assert.True(t, client.backOff(RetryPolicy{BackOffStrategy: BackOffFibonacci, BackOffDelayFactor: 1 << 50}, 30) > 0)
+}
+
+func TestFullJitterStaysWithinBounds(t *testing.T) {
+	random := newLockedRand(nil)
+	seen := map[bool]bool{}
+	for retries := 0; retries < 5; retries++ {
+		base := expBackOff(retries, 30*time.Millisecond)
+		for i := 0; i < 100; i++ {
+			wait := fullJitter(random, base)
+			assert.True(t, wait >= 0 && wait <= base, wait)
+			seen[wait < base/3] = true
+		}
+	}
+	assert.True(t, seen[true], "full jitter also waits less than the relative jitter")
+}
+
+func TestJitterModeOption(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.Jitter = JitterFull
+	_, err := NewClient(opts).Get(nonExistingURL)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 2, len(clock.waits))
+	for i, wait := range clock.waits {
+		assert.True(t, wait <= expBackOff(i, opts.BackOffDelayFactor), wait)
+	}
+}
+
+func TestBackOffStrategies(t *testing.T) {
+	factor := 10 * time.Millisecond
+	var constant, linear []time.Duration
+	for retries := 0; retries < 4; retries++ {
+		constant = append(constant, baseBackOff(BackOffConstant, retries, factor))
+		linear = append(linear, baseBackOff(BackOffLinear, retries, factor))
+	}
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond}, constant)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 40 * time.Millisecond}, linear)
+	assert.Equal(t, 80*time.Millisecond, baseBackOff(BackOffExponential, 3, factor))
+}
+
+func TestLinearBackOffOption(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.DisableJitter = true
+	opts.BackOffStrategy = BackOffLinear
+	_, err := NewClient(opts).Get(nonExistingURL)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}, clock.waits)
+}
+
+func TestFibonacciBackOff(t *testing.T) {
+	var waits []time.Duration
+	for retries := 0; retries < 7; retries++ {
+		waits = append(waits, baseBackOff(BackOffFibonacci, retries, time.Millisecond))
+	}
+	assert.Equal(t, []time.Duration{1, 1, 2, 3, 5, 8, 13}, durationsInMs(waits))
+	assert.Equal(t, fibonacci(maxBackOffExponent), fibonacci(1000))
+}
+
+func durationsInMs(waits []time.Duration) []time.Duration {
+	ms := make([]time.Duration, len(waits))
+	for i, wait := range waits {
+		ms[i] = wait / time.Millisecond
+	}
+	return ms
+}
+
+func TestRetryImmediately(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.DisableJitter = true
+	opts.RetryImmediately = true
+	_, err := NewClient(opts).Get(nonExistingURL)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []time.Duration{0, 5 * time.Millisecond}, clock.waits)
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseURL(t *testing.T) {
+	var paths []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	baseURL, err := url.Parse(fmt.Sprintf("http://localhost:%d/api/", port))
+	assert.Nil(t, err)
+
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be retried and record the path twice
+	opts.Timeout = time.Second
+	opts.BaseURL = baseURL
+	client := NewClient(opts)
+
+	_, err = client.Get("/v1/users?active=true")
+	assert.Nil(t, err)
+	_, err = client.Get("v1/users")
+	assert.Nil(t, err)
+	_, err = client.Post("v1/users", "application/json", strings.NewReader("{}"))
+	assert.Nil(t, err)
+	_, err = client.Get(fmt.Sprintf("http://localhost:%d/absolute", port))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"/v1/users?active=true", "/api/v1/users", "/api/v1/users", "/absolute"}, paths)
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+//BatchResult is the outcome of a request of DoBatch.
+type BatchResult struct {
+	Request  *http.Request
+	Response *http.Response
+	Err      error
+}
+
+//DoBatch sends the requests with at most concurrency requests in flight (all at once if not
+//positive), each retried on its own. Canceling ctx cancels all requests, which keep their own
+//contexts as well. The results are in the order of the requests, the caller has to close the
+//bodies of their responses.
+func (c *FailAwareHTTPClient) DoBatch(ctx context.Context, reqs []*http.Request, concurrency int) []BatchResult {
+	if concurrency <= 0 || concurrency > len(reqs) {
+		concurrency = len(reqs)
+	}
+	results := make([]BatchResult, len(reqs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				reqCtx, cancel := batchContext(ctx, reqs[i].Context())
+				rsp, err := c.Do(reqs[i].WithContext(reqCtx))
+				if rsp == nil {
+					cancel()
+				} else {
+					//the context of the request is needed to read the body
+					rsp.Body = &cancelOnClose{ReadCloser: rsp.Body, cancel: cancel}
+				}
+				results[i] = BatchResult{Request: reqs[i], Response: rsp, Err: err}
+			}
+		}()
+	}
+	for i := range reqs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+//batchContext returns the context of a request of a batch. It keeps the deadline and the values
+//of the context of the request (e.g. its request ID and priority) and is canceled together with
+//the batch as well.
+func batchContext(ctx, reqCtx context.Context) (context.Context, context.CancelFunc) {
+	batchCtx, cancel := context.WithCancel(reqCtx)
+	if ctx.Err() != nil {
+		//no request of a canceled batch is sent
+		cancel()
+		return batchCtx, cancel
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-batchCtx.Done():
+		}
+	}()
+	return batchCtx, cancel
+}
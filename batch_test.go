@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoBatch(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+			return
+		}
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	var reqs []*http.Request
+	for i := 0; i < 10; i++ {
+		path := fmt.Sprintf("/item/%d", i)
+		if i == 3 {
+			path = "/missing"
+		}
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s", port, path), nil)
+		assert.Nil(t, err)
+		reqs = append(reqs, req)
+	}
+	reqs = append(reqs, mustRequest(t, nonExistingURL))
+
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be in flight at the server twice
+	opts.Timeout = time.Second
+	results := NewClient(opts).DoBatch(context.Background(), reqs, 3)
+	assert.Equal(t, 11, len(results))
+	for i, result := range results[:10] {
+		assert.Equal(t, reqs[i], result.Request)
+		assert.Nil(t, result.Err)
+		if i == 3 {
+			assert.Equal(t, 404, result.Response.StatusCode)
+		} else {
+			assert.Equal(t, 200, result.Response.StatusCode)
+		}
+		result.Response.Body.Close()
+	}
+	assert.NotNil(t, results[10].Err)
+	assert.True(t, maxInFlight <= 3)
+}
+
+func TestDoBatchKeepsRequestContext(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.RequestIDHeader = "X-Request-Id"
+	client := NewClient(opts)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	reqs := []*http.Request{
+		mustRequestWithContext(t, WithRequestID(context.Background(), "id-1"), url),
+		mustRequestWithContext(t, canceled, url),
+	}
+	results := client.DoBatch(context.Background(), reqs, 0)
+	if assert.Nil(t, results[0].Err) {
+		assert.Equal(t, "id-1", results[0].Response.Header.Get("X-Request-Id"))
+		results[0].Response.Body.Close()
+	}
+	if assert.IsType(t, FailAwareHTTPError{}, results[1].Err) {
+		assert.Equal(t, KindCanceled, results[1].Err.(FailAwareHTTPError).Kind)
+	}
+
+	batchCtx, cancelBatch := context.WithCancel(context.Background())
+	cancelBatch()
+	results = client.DoBatch(batchCtx, []*http.Request{mustRequest(t, url)}, 0)
+	assert.NotNil(t, results[0].Err, "canceled with the batch")
+}
+
+func mustRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal("unable to create request", err)
+	}
+	return req
+}
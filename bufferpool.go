@@ -0,0 +1,79 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+//bodyBuffers are reused to buffer the bodies of requests for their retries.
+var bodyBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+//maxPooledBuffer is the capacity up to which buffers are reused, a single large body
+//must not stay in memory.
+const maxPooledBuffer = 1 << 20
+
+//pooledBody is a request body buffered in a buffer of the pool. The transport may read the
+//body of an attempt until it closes it, even after the response was returned, so the buffer
+//is released only after all attempt bodies were closed.
+type pooledBody struct {
+	buffer *bytes.Buffer
+	refs   int32
+}
+
+//bufferBody reads the body into a buffer of the pool, release it with release.
+func bufferBody(body io.Reader) (*pooledBody, error) {
+	buffer := bodyBuffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	pooled := &pooledBody{buffer: buffer, refs: 1}
+	if _, err := buffer.ReadFrom(body); err != nil {
+		pooled.release()
+		return nil, err
+	}
+	return pooled, nil
+}
+
+func (b *pooledBody) bytes() []byte {
+	return b.buffer.Bytes()
+}
+
+//reader returns the body for an attempt.
+func (b *pooledBody) reader() io.ReadCloser {
+	atomic.AddInt32(&b.refs, 1)
+	return &pooledReader{Reader: bytes.NewReader(b.buffer.Bytes()), body: b}
+}
+
+func (b *pooledBody) release() {
+	if atomic.AddInt32(&b.refs, -1) == 0 && b.buffer.Cap() <= maxPooledBuffer {
+		bodyBuffers.Put(b.buffer)
+	}
+}
+
+type pooledReader struct {
+	*bytes.Reader
+	body   *pooledBody
+	closed int32
+}
+
+func (r *pooledReader) Close() error {
+	if atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		r.body.release()
+	}
+	return nil
+}
+
+//replaysWithGetBody reports whether the body of the request is created again with GetBody
+//for every attempt instead of being buffered. http.NewRequest sets GetBody for bodies that
+//are in memory anyway (bytes.Buffer, bytes.Reader and strings.Reader), so they are not copied.
+//SignRequest and CompressRequests need the buffered body.
+func (c *FailAwareHTTPClient) replaysWithGetBody(req *http.Request) bool {
+	if req.GetBody == nil {
+		return false
+	}
+	if req.Context().Value(streamedBodyKey) != nil {
+		return true
+	}
+	return c.options().SignRequest == nil && !c.options().CompressRequests
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyReplays(t *testing.T) {
+	var bodies []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	client := NewClient(optionsWithMinTimeouts())
+
+	req, err := http.NewRequest("POST", url, strings.NewReader("in memory"))
+	assert.Nil(t, err)
+	getBody := req.GetBody
+	replays := 0
+	req.GetBody = func() (io.ReadCloser, error) {
+		replays++
+		return getBody()
+	}
+	_, err = client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, replays, "bodies with GetBody are not copied")
+
+	//no GetBody for other readers
+	req, err = http.NewRequest("POST", url, io.MultiReader(strings.NewReader("buff"), strings.NewReader("ered")))
+	assert.Nil(t, err)
+	_, err = client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"in memory", "in memory", "in memory", "buffered", "buffered", "buffered"}, bodies)
+}
+
+func TestPooledBodyReleasedAfterAllReaders(t *testing.T) {
+	pooled, err := bufferBody(strings.NewReader("body"))
+	assert.Nil(t, err)
+	first := pooled.reader()
+	second := pooled.reader()
+	pooled.release()
+	assert.Nil(t, first.Close())
+	assert.Nil(t, first.Close())
+	assert.Equal(t, int32(1), pooled.refs, "closing twice releases once")
+
+	content, err := ioutil.ReadAll(second)
+	assert.Nil(t, err)
+	assert.Equal(t, "body", string(content))
+	assert.Nil(t, second.Close())
+	assert.Equal(t, int32(0), pooled.refs)
+}
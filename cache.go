@@ -0,0 +1,164 @@
+package http
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultCacheSize = 1000
+
+//staleWarning marks responses served from the cache after the retries failed (RFC 7234 5.5.1).
+const staleWarning = `110 - "Response is Stale"`
+
+//cacheEntry is a buffered response.
+type cacheEntry struct {
+	key        string
+	statusCode int
+	header     http.Header
+	body       []byte
+	stored     time.Time
+
+	//only used by the HTTP cache
+	vary           map[string]string
+	lifetime       time.Duration
+	initialAge     time.Duration
+	mustRevalidate bool
+}
+
+//response creates a new response from the entry, every call gets its own body reader.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(e.statusCode),
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+//responseCache is a size bounded LRU cache of responses, safe for concurrent use.
+type responseCache struct {
+	mutex   sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newResponseCache(size int) *responseCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	return &responseCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+func (c *responseCache) get(key string) (*cacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+func (c *responseCache) put(entry *cacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *responseCache) remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+//store buffers the body of the response and puts it into the cache. The body of the
+//response is replaced, so the caller can still read it.
+func (c *responseCache) store(key string, rsp *http.Response, now time.Time) error {
+	body, err := readBody(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return err
+	}
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c.put(&cacheEntry{
+		key:        key,
+		statusCode: rsp.StatusCode,
+		header:     rsp.Header.Clone(),
+		body:       body,
+		stored:     now,
+	})
+	return nil
+}
+
+//IsStale reports whether the response was served from the stale-if-error cache
+//because all retries of the request failed.
+func IsStale(rsp *http.Response) bool {
+	for _, warning := range rsp.Header.Values("Warning") {
+		if warning == staleWarning {
+			return true
+		}
+	}
+	return false
+}
+
+//staleIfError remembers successful GET responses and returns a cached response that is
+//at most StaleIfError old, if the retries of a request failed.
+func (c *FailAwareHTTPClient) staleIfError(req *http.Request, rsp *http.Response, err error) (*http.Response, error) {
+	if c.staleCache == nil || req.Method != "GET" {
+		return rsp, err
+	}
+	key := cacheKey(req)
+	now := c.options().Clock.Now()
+
+	if err == nil {
+		if rsp.StatusCode == http.StatusOK {
+			if storeErr := c.staleCache.store(key, rsp, now); storeErr != nil {
+				return nil, storeErr
+			}
+		}
+		return rsp, nil
+	}
+
+	if _, ok := retriesFailed(err); !ok {
+		return rsp, err
+	}
+	entry, ok := c.staleCache.get(key)
+	if !ok || now.Sub(entry.stored) > c.options().StaleIfError {
+		return rsp, err
+	}
+	if rsp != nil {
+		rsp.Body.Close()
+	}
+	c.options().Logger.Debugf("FAH[Debug]: retries failed, serving stale response stored at %s", entry.stored)
+	stale := entry.response(req)
+	stale.Header.Add("Warning", staleWarning)
+	return stale, nil
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//flakyTransport answers with 200 and the body "ok" until it is switched to fail.
+type flakyTransport struct {
+	failing  int32
+	attempts int32
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.attempts, 1)
+	if atomic.LoadInt32(&t.failing) == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       ioutil.NopCloser(strings.NewReader("ok")),
+		Request:    req,
+	}, nil
+}
+
+func TestStaleIfError(t *testing.T) {
+	transport := &flakyTransport{}
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Transport = transport
+	opts.Clock = clock
+	opts.StaleIfError = time.Minute
+	client := NewClient(opts)
+
+	rsp, err := client.Get("http://example.com/data")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.False(t, IsStale(rsp))
+
+	atomic.StoreInt32(&transport.failing, 1)
+	rsp, err = client.Get("http://example.com/data")
+	assert.Nil(t, err)
+	assert.True(t, IsStale(rsp))
+	body, err = ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, "text/plain", rsp.Header.Get("Content-Type"))
+
+	clock.now = clock.now.Add(time.Hour)
+	_, err = client.Get("http://example.com/data")
+	assert.NotNil(t, err)
+	assert.Equal(t, KindRetriesExhausted, err.(FailAwareHTTPError).Kind)
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newResponseCache(2)
+	cache.put(&cacheEntry{key: "a"})
+	cache.put(&cacheEntry{key: "b"})
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+	cache.put(&cacheEntry{key: "c"})
+
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	_, ok = cache.get("a")
+	assert.True(t, ok)
+	_, ok = cache.get("c")
+	assert.True(t, ok)
+}
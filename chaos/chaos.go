@@ -0,0 +1,116 @@
+//Package chaos injects faults into the attempts of the fail-aware client, to verify
+//the behaviour of a service under retries and retry exhaustion, e.g. in staging.
+package chaos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ErrInjected is the transport error injected if no Errors are configured.
+var ErrInjected = errors.New("chaos: injected fault")
+
+//Options configure the injected faults.
+type Options struct {
+	//FailureRate is the fraction (0 to 1) of attempts that fail.
+	FailureRate float64
+	//Errors are transport errors to fail with, picked at random together with StatusCodes.
+	Errors []error
+	//StatusCodes are the status codes of injected responses.
+	StatusCodes []int
+	//Latency is added to every attempt, faulty or not.
+	Latency time.Duration
+	//RandSource decides which attempts fail, seeded with the current time if not set.
+	RandSource rand.Source
+}
+
+//Transport is an http.RoundTripper that injects faults before passing attempts to the
+//wrapped transport. Use it as FailAwareHTTPOptions.Transport.
+type Transport struct {
+	next     http.RoundTripper
+	options  Options
+	injected int64
+
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+//New wraps next (http.DefaultTransport if nil) with fault injection.
+func New(next http.RoundTripper, options Options) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	source := options.RandSource
+	if source == nil {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+	return &Transport{next: next, options: options, random: rand.New(source)}
+}
+
+//RoundTrip delays the attempt and fails it with the configured probability.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.options.Latency > 0 {
+		timer := time.NewTimer(t.options.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	fail, fault := t.pick()
+	if !fail {
+		return t.next.RoundTrip(req)
+	}
+	atomic.AddInt64(&t.injected, 1)
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	if fault < len(t.options.Errors) {
+		return nil, t.options.Errors[fault]
+	}
+	if len(t.options.StatusCodes) == 0 {
+		return nil, ErrInjected
+	}
+	statusCode := t.options.StatusCodes[fault-len(t.options.Errors)]
+	body := fmt.Sprintf("chaos: injected status %d", statusCode)
+	return &http.Response{
+		Status:        http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+//pick decides whether the attempt fails and with which of the configured faults.
+func (t *Transport) pick() (bool, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.random.Float64() >= t.options.FailureRate {
+		return false, 0
+	}
+	faults := len(t.options.Errors) + len(t.options.StatusCodes)
+	if faults == 0 {
+		return true, 0
+	}
+	return true, t.random.Intn(faults)
+}
+
+//Injected returns the number of faults injected so far.
+func (t *Transport) Injected() int64 {
+	return atomic.LoadInt64(&t.injected)
+}
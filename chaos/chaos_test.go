@@ -0,0 +1,60 @@
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+	"github.com/Ragnaroek/failawarehttp/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllAttemptsFail(t *testing.T) {
+	backend := fake.NewTransport()
+	transport := New(backend, Options{FailureRate: 1, StatusCodes: []int{503}})
+	client := failawarehttp.NewClient(failawarehttp.FailAwareHTTPOptions{
+		Transport: transport,
+		Clock:     fake.NewClock(time.Now()),
+	})
+
+	rsp, err := client.Get("http://example.com/")
+	assert.Nil(t, err)
+	assert.Equal(t, 503, rsp.StatusCode)
+	assert.Equal(t, int64(3), transport.Injected())
+	assert.Equal(t, 0, backend.Attempts())
+}
+
+func TestInjectedErrorsAndPassThrough(t *testing.T) {
+	injected := errors.New("boom")
+	backend := fake.NewTransport(fake.Status(200), fake.Status(200), fake.Status(200), fake.Status(200), fake.Status(200))
+	transport := New(backend, Options{FailureRate: 0.5, Errors: []error{injected}, RandSource: rand.NewSource(1)})
+
+	for i := 0; i < 20 && backend.Remaining() > 0; i++ {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		assert.Nil(t, err)
+		rsp, err := transport.RoundTrip(req)
+		if err != nil {
+			assert.Equal(t, injected, err)
+		} else {
+			assert.Equal(t, 200, rsp.StatusCode)
+		}
+	}
+	assert.Equal(t, 0, backend.Remaining())
+	assert.True(t, transport.Injected() > 0)
+}
+
+func TestLatencyIsAdded(t *testing.T) {
+	transport := New(fake.NewTransport(fake.Status(200)), Options{Latency: 20 * time.Millisecond})
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	assert.Nil(t, err)
+
+	started := time.Now()
+	rsp, err := transport.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.True(t, time.Since(started) >= 20*time.Millisecond)
+	assert.Equal(t, int64(0), transport.Injected())
+}
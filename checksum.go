@@ -0,0 +1,96 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//ErrChecksumMismatch is the error of an attempt whose body does not match its checksum.
+var ErrChecksumMismatch = errors.New("response body does not match checksum")
+
+//Checksum is the expected digest of a response body.
+type Checksum struct {
+	//Hash creates the hash of the digest, e.g. sha256.New.
+	Hash func() hash.Hash
+	//Sum is the expected digest.
+	Sum []byte
+}
+
+//WithChecksum returns a context for requests whose successful response body has to match
+//the checksum. A mismatch is retried like a transport error.
+func WithChecksum(ctx context.Context, checksum Checksum) context.Context {
+	return context.WithValue(ctx, checksumKey, checksum)
+}
+
+//digestAlgorithms are the algorithms of the Digest header (RFC 3230) that are verified.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+//checksums returns the checksums a response has to match: the one of the request context
+//and, with VerifyContentDigest, the ones of its Content-MD5 and Digest headers unless the body
+//was decompressed by the transport or a ContentDecoder.
+func (c *FailAwareHTTPClient) checksums(req *http.Request, rsp *http.Response) []Checksum {
+	var checksums []Checksum
+	if checksum, ok := req.Context().Value(checksumKey).(Checksum); ok {
+		checksums = append(checksums, checksum)
+	}
+	if !c.options().VerifyContentDigest || rsp.Uncompressed {
+		//the digests of the headers are the ones of the encoded body
+		return checksums
+	}
+	if sum, err := base64.StdEncoding.DecodeString(rsp.Header.Get("Content-MD5")); err == nil && len(sum) > 0 {
+		checksums = append(checksums, Checksum{Hash: md5.New, Sum: sum})
+	}
+	for _, digest := range strings.Split(rsp.Header.Get("Digest"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(digest), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		newHash, ok := digestAlgorithms[strings.ToLower(parts[0])]
+		if !ok {
+			continue
+		}
+		if sum, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+			checksums = append(checksums, Checksum{Hash: newHash, Sum: sum})
+		}
+	}
+	return checksums
+}
+
+//verifyChecksum reads the body of a successful response and verifies it against its
+//checksums. The body is replaced by the read bytes.
+func (c *FailAwareHTTPClient) verifyChecksum(req *http.Request, rsp *http.Response) error {
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return nil
+	}
+	checksums := c.checksums(req, rsp)
+	if len(checksums) == 0 {
+		return nil
+	}
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for _, checksum := range checksums {
+		h := checksum.Hash()
+		h.Write(body)
+		if !bytes.Equal(h.Sum(nil), checksum.Sum) {
+			return ErrChecksumMismatch
+		}
+	}
+	return nil
+}
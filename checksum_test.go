@@ -0,0 +1,106 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func corruptingServer(t *testing.T, content string, corrupted int, header func(http.Header)) int {
+	requests := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		header(w.Header())
+		if requests <= corrupted {
+			w.Write([]byte("corrupted"))
+			return
+		}
+		w.Write([]byte(content))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	return port
+}
+
+func TestChecksumRefetch(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	port := corruptingServer(t, "content", 2, func(http.Header) {})
+	client := NewClient(optionsWithMinTimeouts())
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	req = req.WithContext(WithChecksum(context.Background(), Checksum{Hash: sha256.New, Sum: sum[:]}))
+	rsp, err := client.Do(req)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(body))
+}
+
+func TestChecksumMismatchExhausted(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	port := corruptingServer(t, "content", 3, func(http.Header) {})
+	client := NewClient(optionsWithMinTimeouts())
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	req = req.WithContext(WithChecksum(context.Background(), Checksum{Hash: sha256.New, Sum: sum[:]}))
+	_, err = client.Do(req)
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, 3, failErr.Retries)
+	assert.True(t, errors.Is(failErr.LastError, ErrChecksumMismatch))
+}
+
+func TestVerifyContentDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	port := corruptingServer(t, "content", 1, func(header http.Header) {
+		header.Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+	})
+	opts := optionsWithMinTimeouts()
+	opts.VerifyContentDigest = true
+
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(body))
+}
+
+func TestVerifyContentDigestSkipsDecompressedBody(t *testing.T) {
+	var encoded bytes.Buffer
+	writer := gzip.NewWriter(&encoded)
+	writer.Write([]byte("content"))
+	writer.Close()
+	sum := md5.Sum(encoded.Bytes())
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		w.Write(encoded.Bytes())
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = time.Second
+	opts.VerifyContentDigest = true
+
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.True(t, rsp.Uncompressed)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(body))
+}
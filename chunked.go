@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const defaultChunkSize = 5 << 20
+
+//ChunkedUploadOptions configure UploadChunked. The zero value uploads 5 MiB chunks.
+type ChunkedUploadOptions struct {
+	//ChunkSize is the size of a chunk in bytes.
+	ChunkSize int64
+	//Progress is called after every acknowledged chunk.
+	Progress func(uploaded, total int64)
+}
+
+//UploadChunked uploads size bytes of content to an upload resource with the offset-based
+//protocol of tus (tus.io): the offset acknowledged by the server is requested with HEAD
+//and the remaining content is sent in PATCH requests of ChunkSize bytes, each retried on
+//its own. An interrupted upload resumes from the last acknowledged chunk by calling
+//UploadChunked again. Returns the number of bytes acknowledged by the server.
+func (c *FailAwareHTTPClient) UploadChunked(ctx context.Context, url string, content io.ReaderAt, size int64, options ChunkedUploadOptions) (int64, error) {
+	chunkSize := options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	//a chunk is sent for an offset, sending it again can not apply it twice
+	ctx = WithUnsafeRetry(ctx)
+
+	policyReq, err := http.NewRequest("PATCH", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	maxConflicts := c.retryPolicy(policyReq).attempts()
+	offset, err := c.uploadOffset(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	conflicts := 0
+	for offset < size {
+		length := chunkSize
+		if offset+length > size {
+			length = size - offset
+		}
+		acknowledged, err := c.uploadChunk(ctx, url, io.NewSectionReader(content, offset, length), offset)
+		if err == errOffsetConflict && (maxConflicts < 0 || conflicts < maxConflicts) {
+			//the server has another offset, e.g. after an acknowledgement got lost
+			conflicts++
+			if acknowledged, err = c.uploadOffset(ctx, url); err != nil {
+				return offset, err
+			}
+		} else if err != nil {
+			return offset, err
+		}
+		offset = acknowledged
+		if options.Progress != nil {
+			options.Progress(offset, size)
+		}
+	}
+	return offset, nil
+}
+
+var errOffsetConflict = errors.New("upload offset does not match the offset of the server")
+
+func (c *FailAwareHTTPClient) uploadOffset(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	rsp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNoContent {
+		return 0, fmt.Errorf("requesting upload offset failed with status %d", rsp.StatusCode)
+	}
+	return parseUploadOffset(rsp)
+}
+
+func (c *FailAwareHTTPClient) uploadChunk(ctx context.Context, url string, chunk io.Reader, offset int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "PATCH", url, chunk)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	rsp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusConflict {
+		return 0, errOffsetConflict
+	}
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNoContent {
+		return 0, fmt.Errorf("uploading chunk at offset %d failed with status %d", offset, rsp.StatusCode)
+	}
+	return parseUploadOffset(rsp)
+}
+
+func parseUploadOffset(rsp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(rsp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Upload-Offset %q", rsp.Header.Get("Upload-Offset"))
+	}
+	return offset, nil
+}
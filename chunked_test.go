@@ -0,0 +1,87 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//tusServer stores the uploaded bytes, every failEvery-th PATCH fails with a 503
+type tusServer struct {
+	mutex     sync.Mutex
+	received  bytes.Buffer
+	patches   int
+	failEvery int
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if r.Method == "PATCH" {
+		s.patches++
+		if s.failEvery > 0 && s.patches%s.failEvery == 0 {
+			w.WriteHeader(503)
+			return
+		}
+		offset, _ := strconv.Atoi(r.Header.Get("Upload-Offset"))
+		if offset != s.received.Len() {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		chunk, _ := ioutil.ReadAll(r.Body)
+		s.received.Write(chunk)
+	}
+	w.Header().Set("Upload-Offset", strconv.Itoa(s.received.Len()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestUploadChunked(t *testing.T) {
+	server := &tusServer{failEvery: 2}
+	port, err := serverWithHandler(server.ServeHTTP)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	content := strings.Repeat("0123456789", 100)
+
+	var progress []int64
+	uploaded, err := NewClient(optionsWithMinTimeouts()).UploadChunked(context.Background(), fmt.Sprintf("http://localhost:%d", port),
+		strings.NewReader(content), int64(len(content)), ChunkedUploadOptions{
+			ChunkSize: 300,
+			Progress: func(uploaded, total int64) {
+				progress = append(progress, uploaded)
+			},
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), uploaded)
+	assert.Equal(t, []int64{300, 600, 900, 1000}, progress)
+	assert.Equal(t, content, server.received.String())
+}
+
+func TestUploadChunkedResumes(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	server := &tusServer{}
+	server.received.WriteString(content[:400])
+	port, err := serverWithHandler(server.ServeHTTP)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	//a chunk timing out on a slow machine (e.g. with -race) would be resent
+	opts.Timeout = time.Second
+	uploaded, err := NewClient(opts).UploadChunked(context.Background(), fmt.Sprintf("http://localhost:%d", port),
+		strings.NewReader(content), int64(len(content)), ChunkedUploadOptions{ChunkSize: 300})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), uploaded)
+	assert.Equal(t, 2, server.patches)
+	assert.Equal(t, content, server.received.String())
+}
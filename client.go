@@ -3,71 +3,316 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-var random *rand.Rand
+//defaultLogger logs with the formatter, output and hooks of the standard logrus logger, but
+//with a level of its own, so the level of other users of the standard logger is not changed.
+//The level is the LogLevel option, else the LOG_LEVEL environment variable, else error.
+//An unknown level is logged as warning and error is used.
+func defaultLogger(level string) Logger {
+	standard := logrus.StandardLogger()
+	logger := logrus.New()
+	logger.Out = standard.Out
+	logger.Formatter = standard.Formatter
+	logger.Hooks = standard.Hooks
+	logger.ReportCaller = standard.ReportCaller
 
-func init() {
-	random = rand.New(rand.NewSource(time.Now().UnixNano()))
-}
-
-func defaultLogger() Logger {
-	logger := logrus.StandardLogger()
-	logrus.SetLevel(logLevel())
-	return logger
-}
-
-func logLevel() logrus.Level {
-	logEnv := os.Getenv("LOG_LEVEL")
-	switch logEnv {
-	case "": //not set
-		return logrus.ErrorLevel
-	case "panic":
-		return logrus.PanicLevel
-	case "fatal":
-		return logrus.FatalLevel
-	case "error":
-		return logrus.ErrorLevel
-	case "warn":
-		return logrus.WarnLevel
-	case "info":
-		return logrus.InfoLevel
-	case "debug":
-		return logrus.DebugLevel
-	case "trace":
-		return logrus.TraceLevel
+	source := "LogLevel"
+	if level == "" {
+		level, source = os.Getenv("LOG_LEVEL"), "LOG_LEVEL"
 	}
-
-	panic(fmt.Sprintf("LOG_LEVEL %s is not known", logEnv))
+	logger.SetLevel(logrus.ErrorLevel)
+	if level != "" {
+		parsed, err := logrus.ParseLevel(level)
+		if err != nil {
+			logger.Warnf("FAH[Warn]: %s %q is not known, logging errors only", source, level)
+		} else {
+			logger.SetLevel(parsed)
+		}
+	}
+	return logrusLogger{logger}
 }
 
 //FailAwareHTTPClient is the extendes HTTP client. It provides the same methods as the
 //http.Client.
 type FailAwareHTTPClient struct {
-	httpClient *http.Client
-	options    FailAwareHTTPOptions
+	httpClient    *http.Client
+	redactHeaders map[string]bool
+	random        *lockedRand
+	staleCache    *responseCache
+	httpCache     *responseCache
+	flights       *flightGroup
+	dnsCache      *dnsCache
+	stats         *clientStats
+	pool          *endpointPool
+	events        *retryEvents
+	pacer         *rateLimitPacer
+	tracker       *requestTracker
+	limiter       *concurrencyLimiter
+	retryLimiter  *concurrencyLimiter
+	localState    StateStore
+
+	//mutex guards the middlewares and the options, see Use and UpdateOptions
+	mutex       sync.RWMutex
+	middlewares []Middleware
+	chain       RoundTripFunc
+	opts        *FailAwareHTTPOptions
 }
 
 //FailAwareHTTPOptions are the options for the FFailAwareHttp client.
 //See NewClient(options) and ddefaultOptions.
 type FailAwareHTTPOptions struct {
+	//MaxRetries is the number of attempts (not retries) of a request.
+	//Deprecated: use MaxAttempts, which also allows to retry until the context is done.
 	MaxRetries         int
 	Timeout            time.Duration
 	BackOffDelayFactor time.Duration
 	KeepLog            bool
 	Logger             Logger
+	//LogLevel of the default Logger (panic, fatal, error, warn, info, debug or trace), the
+	//LOG_LEVEL environment variable if not set and error if neither is set. Ignored with a Logger.
+	LogLevel        string
+	RequestLogHook  RequestLogHook
+	ResponseLogHook ResponseLogHook
+	//RedactHeaders are redacted in addition to Authorization, Proxy-Authorization,
+	//Cookie and Set-Cookie before requests and responses are logged.
+	RedactHeaders []string
+	//Clock used for timestamps and backoff waits, the wall clock if not set.
+	Clock Clock
+	//RandSource for the backoff jitter, seeded with the current time if not set.
+	//The client synchronizes the access to the source.
+	RandSource rand.Source
+	//LatencySLOs declares the expected attempt latency per endpoint (host[:port][/path]).
+	//Slower attempts are flagged in the error log and reported to the SLOViolationHook.
+	LatencySLOs      map[string]time.Duration
+	SLOViolationHook SLOViolationHook
+	//DisableJitter makes the backoff a pure exponential backoff without jitter.
+	DisableJitter bool
+	//Transport used for the attempts. If not set, every client gets its own transport, a clone
+	//of http.DefaultTransport with the ProxyURL and NoProxy, the TLSConfig, the connection pool
+	//tuning (MaxIdleConns to IdleConnTimeout), the dial and response header timeouts, the
+	//DNSCacheTTL, DualStackFallback, UnixSocket and H2C applied. All of them are ignored with a
+	//custom Transport, it has to be configured itself.
+	Transport http.RoundTripper
+	//Fallback is called if all retries failed, its result is returned instead of the error.
+	Fallback Fallback
+	//StaleIfError enables the caching of successful GET responses. If all retries of a GET
+	//fail, a cached response that is at most StaleIfError old is returned instead of the
+	//error. See IsStale.
+	StaleIfError time.Duration
+	//StaleCacheSize is the maximum number of cached responses, 1000 if not set.
+	StaleCacheSize int
+	//HTTPCache enables a private HTTP cache (RFC 7234) for GET and HEAD requests. Fresh
+	//responses are served without a request to the server, stale responses with an ETag
+	//or Last-Modified header are revalidated with a conditional request. A successful POST,
+	//PUT, PATCH or DELETE invalidates the cached responses of its URL. See IsCached.
+	HTTPCache bool
+	//HTTPCacheSize is the maximum number of responses in the HTTP cache, 1000 if not set.
+	HTTPCacheSize int
+	//CoalesceRequests shares the result of a GET with all identical GETs issued while it is
+	//in flight, instead of sending each of them. GETs are identical if they have the same
+	//URL and the same values for the CoalesceHeaders.
+	CoalesceRequests bool
+	//CoalesceHeaders are the headers that distinguish GETs, defaults to Accept,
+	//Accept-Encoding, Accept-Language, Authorization and Cookie.
+	CoalesceHeaders []string
+	//AllowUnsafeRetry retries requests with non-idempotent methods (POST, PATCH, ...) too.
+	//By default only idempotent requests are retried, see WithUnsafeRetry to allow the
+	//retry of a single request.
+	AllowUnsafeRetry bool
+	//MethodPolicies overrides the retry policy per HTTP method (e.g. "DELETE").
+	MethodPolicies map[string]RetryPolicy
+	//HostPolicies overrides the retry policy per destination host, either with ("api:8080")
+	//or without port ("api"). Host policies take precedence over method policies.
+	HostPolicies map[string]RetryPolicy
+	//ReResolveOnRetry closes the idle connections of the client after an attempt failed with
+	//a transport error. The retry dials a new connection and resolves the host again, so it
+	//can reach a replacement after a failover instead of the dead address.
+	ReResolveOnRetry bool
+	//DNSCacheTTL enables a DNS cache that keeps resolved addresses for the TTL. Hosts are
+	//evicted if no connection could be established. Only used with the default Transport.
+	DNSCacheTTL time.Duration
+	//DualStackFallback dials the retry of a request over IPv4 if an attempt failed to connect
+	//over IPv6, and vice versa. Only used with the default Transport.
+	DualStackFallback bool
+	//UnixSocket is the path of a Unix domain socket all requests are sent to, regardless of
+	//the host of their URL. URLs with the scheme unix address a socket per request, the path
+	//of the socket is followed by a colon and the request path (unix:///run/app.sock:/status).
+	//Both are only supported with the default Transport.
+	UnixSocket string
+	//ProxyURL is the proxy for all requests. If not set, the proxy is taken from the
+	//HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL *url.URL
+	//IgnoreProxyEnvironment ignores the proxy environment variables.
+	IgnoreProxyEnvironment bool
+	//NoProxy are hosts (including their subdomains), IPs or CIDR ranges that are never proxied.
+	NoProxy []string
+	//CheckRedirect decides whether a redirect is followed, see http.Client.CheckRedirect.
+	//Returning http.ErrUseLastResponse returns the redirect response itself.
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+	//MaxRedirects limits the number of redirects followed for a request. Defaults to 10
+	//redirects, a negative value disables following redirects.
+	MaxRedirects int
+	//TLSConfig is the TLS configuration (client certificates, root CAs, minimum version) of
+	//the default Transport. A custom Transport has to be configured itself.
+	TLSConfig *tls.Config
+	//TokenSource provides the bearer token for every attempt. After a 401 the token is
+	//refreshed once and the request retried, which counts as a retry.
+	TokenSource TokenSource
+	//SignRequest signs every attempt, e.g. with sigv4.Signer. Middlewares run after
+	//signing and must not change signed headers.
+	SignRequest SignRequest
+	//PrepareRetry changes the request before every retry, an error aborts the retries.
+	PrepareRetry PrepareRetry
+	//RequestIDHeader is the header (e.g. X-Request-ID) the request ID is sent with on every
+	//attempt. The ID is taken from the context (see WithRequestID), from the header of the
+	//request or generated. Without header only IDs from the context are used.
+	RequestIDHeader string
+	//RetryAttemptHeader is the header (e.g. X-Retry-Attempt) with the number of the retry,
+	//added to retried requests so servers can tell them from fresh ones.
+	RetryAttemptHeader string
+	//DefaultHeaders are sent with every request that does not set them itself.
+	DefaultHeaders http.Header
+	//UserAgent is sent as User-Agent by every request that does not set one itself.
+	UserAgent string
+	//BaseURL resolves the relative URLs of requests (client.Get("/v1/users")) like links
+	//in a document: "v1/users" is relative to the path of BaseURL, "/v1/users" is not.
+	BaseURL *url.URL
+	//RetryableErrors are the classes of transport errors that are retried, all if nil.
+	//Certificate errors (ClassCertificate) are never retried.
+	//Other transport errors fail the request immediately with KindNotRetryable.
+	RetryableErrors []ErrorClass
+	//VerifyContentDigest verifies successful responses against their Content-MD5 or Digest
+	//header. A mismatch is retried like a transport error, see also WithChecksum. Decompressed
+	//responses are not verified, the headers describe the encoded body.
+	VerifyContentDigest bool
+	//MaxBackOff caps the wait before a single retry, no matter how many retries preceded it.
+	MaxBackOff time.Duration
+	//Jitter selects how the back off is randomized, see JitterMode. Ignored with DisableJitter.
+	Jitter JitterMode
+	//BackOffStrategy selects how the back off grows with the retries, exponential by default.
+	BackOffStrategy BackOffStrategy
+	//RetryImmediately sends the first retry without waiting, e.g. after a dropped keep-alive
+	//connection. The back off starts with the second retry.
+	RetryImmediately bool
+	//MaxAttempts is the number of attempts of a request: 1 sends it without retries,
+	//RetryForever retries until its context is done. If not set, MaxRetries applies.
+	MaxAttempts int
+	//ExpvarName publishes the Stats of the client with expvar under this name, e.g. to
+	//be scraped from /debug/vars. Not published if empty or the name is already taken.
+	ExpvarName string
+	//EventBufferSize is the capacity of the channel of Events, 256 if not set.
+	EventBufferSize int
+	//FailureRateWindow is the sliding window of FailureRate, 1 minute if not set.
+	FailureRateWindow time.Duration
+	//Endpoints the relative URLs of requests are resolved against instead of BaseURL.
+	//Every attempt picks an endpoint by weighted round robin, retries fail over to the next endpoint.
+	Endpoints []Endpoint
+	//Resolver yields the Endpoints dynamically, they are resolved again once their TTL expired.
+	Resolver Resolver
+	//OutlierDetection ejects endpoints from the pool whose failure rate is much worse than
+	//the one of the other endpoints. Disabled if nil.
+	OutlierDetection *OutlierDetection
+	//H2C sends requests to http:// URLs with HTTP/2 cleartext with prior knowledge, e.g. for
+	//Envoy or gRPC gateways. Requests to https:// URLs use HTTP/2 only as well. Applies to the
+	//default Transport, requires Go 1.24.
+	H2C bool
+	//MaxIdleConns, MaxIdleConnsPerHost, MaxConnsPerHost and IdleConnTimeout tune the connection
+	//pool of the default Transport, see http.Transport. The defaults of http.DefaultTransport
+	//apply if not set. High request rates to few hosts need more idle connections per host
+	//than the default of 2, otherwise connections are closed and ephemeral ports run out.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	//DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout limit the phases of an attempt
+	//with the default Transport, so a slow connect fails fast while the Timeout of the attempt
+	//still allows long downloads. The defaults of http.DefaultTransport apply if not set.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	//ExpectContinueThreshold sends requests with a larger body (in bytes) with
+	//"Expect: 100-continue", so a server that rejects the request does so before the body
+	//is transmitted, on every attempt. The default Transport waits up to one second for
+	//the server to continue. Disabled if not set.
+	ExpectContinueThreshold int64
+	//CompressRequests gzips the bodies of requests and sends them with Content-Encoding.
+	//The body is compressed once and replayed compressed on every retry. Streamed bodies
+	//and bodies that already have a Content-Encoding are sent as they are.
+	CompressRequests bool
+	//ContentDecoders decode response bodies by their Content-Encoding in addition to gzip,
+	//which net/http decodes itself. The encodings are advertised with Accept-Encoding if
+	//the request has no Accept-Encoding. See the compress module for zstd and brotli.
+	ContentDecoders map[string]ContentDecoder
+	//MaxResponseBytes limits the size of response bodies. Reading beyond the limit fails
+	//with ResponseTooLargeError, a response that declares a larger Content-Length fails the
+	//request with KindResponseTooLarge. Not limited if not set.
+	MaxResponseBytes int64
+	//RateLimitWaits waits as long as a 429 or 503 response asks for with Retry-After or until
+	//the quota resets (RateLimit, RateLimit-Reset or X-RateLimit-Reset) before the retry,
+	//instead of the back off. The wait is capped by MaxBackOff.
+	RateLimitWaits bool
+	//PaceRateLimits spreads the requests to a host over the rest of its rate limit window, as
+	//learned from the RateLimit or X-RateLimit headers of its responses, so that the quota is
+	//not exceeded. Without any quota left the requests wait until the quota resets.
+	PaceRateLimits bool
+	//ValidateResponse checks the responses that are not retried because of their status, an
+	//error fails the attempt. The body of the response is buffered for the validator.
+	ValidateResponse ResponseValidator
+	//ReturnLastResponse returns a FailAwareHTTPError with the response of the last attempt if
+	//the request gives up on a retryable status (e.g. after repeated 503s), instead of only the
+	//response. The LastError of the error is ErrRetryableStatus, the body of the response must
+	//be closed. The Fallback is called for these requests too.
+	ReturnLastResponse bool
+	//CaptureErrorBodyBytes captures up to this many bytes of the body of responses with an
+	//error status in the ErrEntry of their attempt, see ErrEntry.Body. Error payloads often
+	//name the actual reason of a 5xx. Only with KeepLog, not captured if not set.
+	CaptureErrorBodyBytes int64
+	//MaxConcurrentRequests limits the requests the client sends and retries at once, a request
+	//beyond the limit fails at once with KindShed and ErrShed instead of piling up. Requests
+	//with PriorityLow are already shed at half of the limit and requests with PriorityNormal
+	//at 90% of it, the rest is left for PriorityHigh (see WithPriority). Not limited if not set.
+	MaxConcurrentRequests int
+	//MaxQueueWait lets the requests beyond MaxConcurrentRequests wait up to this long for a
+	//slot instead of shedding them at once. The queued requests get the free slots by priority,
+	//the ones that could not start in time fail with KindQueueTimeout and ErrQueueTimeout.
+	MaxQueueWait time.Duration
+	//MaxConcurrentRetries limits the requests of the client that are retrying at once, from
+	//their first retry until they are done. A request that would start retrying beyond the
+	//limit gives up with KindTooManyRetries instead, so that an outage does not turn every
+	//caller into a retry loop. The shares of the priorities are the same as for the
+	//MaxConcurrentRequests. Not limited if not set.
+	MaxConcurrentRetries int
+	//RetryBudget limits the retries to a host within a window, shared by all replicas that
+	//use the same StateStore. Disabled if nil.
+	RetryBudget *RetryBudget
+	//StateStore shares the RetryBudget and the ejections of the OutlierDetection with other
+	//clients, e.g. the replicas of a service via Redis. The RetryBudget is kept in memory of
+	//the client and ejections are not shared if not set.
+	StateStore StateStore
+	//StateKeyPrefix is the prefix of the keys of the client in the StateStore, "failawarehttp:"
+	//if not set. Clients with the same prefix share their state.
+	StateKeyPrefix string
 }
 
+//Fallback provides a response (e.g. a default or cached value) for a request whose
+//retries failed. The body of the request is already consumed when it is called.
+type Fallback func(req *http.Request, err FailAwareHTTPError) (*http.Response, error)
+
 var defaultOptions = NewDefaultOptions()
 var nullOptions = FailAwareHTTPOptions{}
 
@@ -79,6 +324,7 @@ func NewDefaultOptions() FailAwareHTTPOptions {
 		BackOffDelayFactor: 1 * time.Second,
 		KeepLog:            false,
 		Logger:             nil, //use default logrus logger
+		Clock:              realClock{},
 	}
 }
 
@@ -89,7 +335,57 @@ func NewDefaultClient() *FailAwareHTTPClient {
 
 //NewClient creates a new FFailAwareHTTP client.
 func NewClient(options FailAwareHTTPOptions) *FailAwareHTTPClient {
+	effectiveOptions := withDefaults(options)
 
+	var dnsCache *dnsCache
+	transport := effectiveOptions.Transport
+	if transport == nil {
+		transport, dnsCache = newTransport(effectiveOptions)
+	}
+	return newClient(effectiveOptions, transport, dnsCache, newLockedRand(effectiveOptions.RandSource))
+}
+
+//newClient creates a client that sends its requests with the transport.
+func newClient(effectiveOptions FailAwareHTTPOptions, transport http.RoundTripper, dnsCache *dnsCache, random *lockedRand) *FailAwareHTTPClient {
+	//the timeout is applied per attempt with the context of the attempt, see RetryPolicy.Timeout
+	client := http.Client{
+		Transport:     transport,
+		CheckRedirect: checkRedirect(effectiveOptions),
+	}
+	var staleCache *responseCache
+	if effectiveOptions.StaleIfError > 0 {
+		staleCache = newResponseCache(effectiveOptions.StaleCacheSize)
+	}
+	var httpCache *responseCache
+	if effectiveOptions.HTTPCache {
+		httpCache = newResponseCache(effectiveOptions.HTTPCacheSize)
+	}
+	fahClient := &FailAwareHTTPClient{
+		httpClient:    &client,
+		redactHeaders: redactedHeaderNames(effectiveOptions.RedactHeaders),
+		random:        random,
+		staleCache:    staleCache,
+		httpCache:     httpCache,
+		flights:       newFlightGroup(),
+		dnsCache:      dnsCache,
+		stats:         newClientStats(effectiveOptions.FailureRateWindow),
+		pool:          newEndpointPool(effectiveOptions.Endpoints, effectiveOptions.Resolver),
+		events:        newRetryEvents(effectiveOptions.EventBufferSize),
+		pacer:         newRateLimitPacer(effectiveOptions.PaceRateLimits),
+		tracker:       newRequestTracker(),
+		limiter:       &concurrencyLimiter{},
+		retryLimiter:  &concurrencyLimiter{},
+		localState:    newMemoryStateStore(effectiveOptions.Clock),
+		opts:          &effectiveOptions,
+	}
+	if effectiveOptions.ExpvarName != "" {
+		fahClient.publishExpvar(effectiveOptions.ExpvarName)
+	}
+	return fahClient
+}
+
+//withDefaults replaces the unset options that have a default by the default.
+func withDefaults(options FailAwareHTTPOptions) FailAwareHTTPOptions {
 	var timeout time.Duration
 	if options.Timeout == nullOptions.Timeout {
 		timeout = defaultOptions.Timeout
@@ -113,26 +409,26 @@ func NewClient(options FailAwareHTTPOptions) *FailAwareHTTPClient {
 
 	var logger Logger
 	if options.Logger == nullOptions.Logger {
-		logger = defaultLogger()
+		logger = defaultLogger(options.LogLevel)
 	} else {
 		logger = options.Logger
 	}
 
-	effectiveOptions := FailAwareHTTPOptions{
-		Timeout:            timeout,
-		MaxRetries:         maxRetries,
-		BackOffDelayFactor: backOffDelay,
-		KeepLog:            options.KeepLog,
-		Logger:             logger,
+	var clock Clock
+	if options.Clock == nullOptions.Clock {
+		clock = defaultOptions.Clock
+	} else {
+		clock = options.Clock
 	}
 
-	client := http.Client{
-		Timeout: effectiveOptions.Timeout,
-	}
-	return &FailAwareHTTPClient{
-		httpClient: &client,
-		options:    effectiveOptions,
-	}
+	//options without a default are taken as they are
+	effectiveOptions := options
+	effectiveOptions.Timeout = timeout
+	effectiveOptions.MaxRetries = maxRetries
+	effectiveOptions.BackOffDelayFactor = backOffDelay
+	effectiveOptions.Logger = logger
+	effectiveOptions.Clock = clock
+	return effectiveOptions
 }
 
 //ErrEntry is used for logging retries and the result of retries.
@@ -141,22 +437,147 @@ type ErrEntry struct {
 	response          *http.Response
 	timestampStarted  time.Time
 	timestampFinished time.Time
+	sloExceeded       bool
+	reason            FailureReason
+	body              []byte
+	backOff           time.Duration
 }
 
-func errEntryNow(err error, rsp *http.Response, started time.Time) ErrEntry {
+func errEntryFinished(err error, rsp *http.Response, started, finished time.Time, sloExceeded bool, reason FailureReason, body []byte) ErrEntry {
 	return ErrEntry{
 		err:               err,
 		response:          rsp,
 		timestampStarted:  started,
-		timestampFinished: time.Now(),
+		timestampFinished: finished,
+		sloExceeded:       sloExceeded,
+		reason:            reason,
+		body:              body,
 	}
 }
 
+//SLOExceeded reports whether the attempt took longer than the latency SLO of its endpoint.
+func (e ErrEntry) SLOExceeded() bool {
+	return e.sloExceeded
+}
+
+//Duration of the attempt, from sending the request until the response headers or the error.
+func (e ErrEntry) Duration() time.Duration {
+	return e.timestampFinished.Sub(e.timestampStarted)
+}
+
+//BackOff is the back off waited after the attempt, as chosen by the policy including the
+//jitter. 0 if the client did not wait after the attempt.
+func (e ErrEntry) BackOff() time.Duration {
+	return e.backOff
+}
+
+//Reason reports why the attempt was considered a failure, ReasonNone if it was not.
+func (e ErrEntry) Reason() FailureReason {
+	return e.reason
+}
+
+//Body returns the start of the body of the response of the attempt, if it had an error
+//status, see FailAwareHTTPOptions.CaptureErrorBodyBytes.
+func (e ErrEntry) Body() []byte {
+	return e.body
+}
+
+//ErrorClass returns the class of the transport error of the attempt, only meaningful
+//with ReasonTransportError.
+func (e ErrEntry) ErrorClass() ErrorClass {
+	return ClassifyError(e.err)
+}
+
+//ErrorKind classifies why a FailAwareHTTP request failed.
+type ErrorKind int
+
+const (
+	//KindRetriesExhausted all attempts failed with a retrieable error.
+	KindRetriesExhausted ErrorKind = iota
+	//KindCanceled the request context was canceled.
+	KindCanceled
+	//KindRequestTooLarge the server rejected the payload (413) or the header set (431).
+	//Retrying can never succeed, so no further attempts are made.
+	KindRequestTooLarge
+	//KindRetryBudgetExhausted the retry budget shared with other requests (see RequestGroup and
+	//FailAwareHTTPOptions.RetryBudget) is used up.
+	KindRetryBudgetExhausted
+	//KindUnsafeRetry the request failed, but its method is not idempotent and unsafe retries
+	//are not allowed, see FailAwareHTTPOptions.AllowUnsafeRetry.
+	KindUnsafeRetry
+	//KindPrepareFailed an attempt could not be prepared, signing it, PrepareRetry or resolving
+	//the endpoints (see Resolver) failed.
+	KindPrepareFailed
+	//KindNotRetryable the attempt failed with a transport error that is not retried,
+	//see FailAwareHTTPOptions.RetryableErrors.
+	KindNotRetryable
+	//KindDeadlineExceeded the next retry could not complete before the deadline of the request
+	//context, so it was not started.
+	KindDeadlineExceeded
+	//KindResponseTooLarge the response declared a body larger than
+	//FailAwareHTTPOptions.MaxResponseBytes.
+	KindResponseTooLarge
+	//KindShed the client was at its concurrency limit for the priority of the request, so it
+	//was not sent, see FailAwareHTTPOptions.MaxConcurrentRequests.
+	KindShed
+	//KindQueueTimeout the request could not start within the FailAwareHTTPOptions.MaxQueueWait
+	//at the concurrency limit.
+	KindQueueTimeout
+	//KindTooManyRetries the request would have been retried, but the client was at its
+	//FailAwareHTTPOptions.MaxConcurrentRetries.
+	KindTooManyRetries
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindRetriesExhausted:
+		return "retries exhausted"
+	case KindCanceled:
+		return "canceled"
+	case KindRequestTooLarge:
+		return "request too large"
+	case KindRetryBudgetExhausted:
+		return "retry budget exhausted"
+	case KindUnsafeRetry:
+		return "unsafe retry"
+	case KindPrepareFailed:
+		return "prepare failed"
+	case KindNotRetryable:
+		return "not retryable"
+	case KindDeadlineExceeded:
+		return "deadline exceeded"
+	case KindResponseTooLarge:
+		return "response too large"
+	case KindShed:
+		return "shed"
+	case KindQueueTimeout:
+		return "queue timeout"
+	case KindTooManyRetries:
+		return "too many retries"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+//ErrRequestTooLarge is the LastError of a request rejected with 413 or 431.
+var ErrRequestTooLarge = errors.New("request rejected by server as too large")
+
+//ErrRetryableStatus is the LastError of a request that gave up on a retryable status,
+//see FailAwareHTTPOptions.ReturnLastResponse.
+var ErrRetryableStatus = errors.New("gave up on retryable status")
+
 //FailAwareHTTPError structured error returned by the FailAwareHTTP methods.
 type FailAwareHTTPError struct {
+	Kind      ErrorKind
 	Retries   int
 	Errors    []ErrEntry
 	LastError error
+	//RequestID of the request, empty if it has none, see FailAwareHTTPOptions.RequestIDHeader.
+	RequestID string
+	//TotalWait is the time spent waiting the back offs between the attempts.
+	TotalWait time.Duration
+	//TotalElapsed is the time from sending the first attempt until the request failed,
+	//the attempts take TotalElapsed minus TotalWait.
+	TotalElapsed time.Duration
 }
 
 func (e FailAwareHTTPError) Error() string {
@@ -182,8 +603,90 @@ func (c *FailAwareHTTPClient) Post(url, contentType string, body io.Reader) (res
 }
 
 //Do sends an arbitrary request and retries in the case of an retrieable error
-func (c *FailAwareHTTPClient) Do(originalReq *http.Request) (*http.Response, error) {
-	originalBody, err := readBody(originalReq.Body)
+func (c *FailAwareHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	ctx, cancel, finish, err := c.tracker.start(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+	rsp, err := c.doTracked(req.WithContext(ctx))
+	if rsp == nil {
+		cancel()
+		return nil, err
+	}
+	if rsp.Body == nil {
+		//e.g. a response of the Fallback
+		rsp.Body = http.NoBody
+	}
+	//the context of the request is needed to read the body
+	rsp.Body = &cancelOnClose{ReadCloser: rsp.Body, cancel: cancel}
+	return rsp, err
+}
+
+//doTracked sends a request that is tracked for Close.
+func (c *FailAwareHTTPClient) doTracked(req *http.Request) (*http.Response, error) {
+	req = c.resolveURL(req)
+	cached, fresh := c.lookupHTTPCache(req)
+	if fresh {
+		return cachedResponse(req, cached), nil
+	}
+	rsp, err := c.coalesce(req, func() (*http.Response, error) {
+		conditionalReq := conditionalRequest(req, cached)
+		rsp, err := c.do(conditionalReq)
+		if conditionalReq != req {
+			rsp, err = c.revalidated(req, cached, rsp, err)
+		}
+		c.invalidateHTTPCache(req, rsp, err)
+		return c.storeInHTTPCache(req, rsp, err)
+	})
+	rsp, err = c.staleIfError(req, rsp, err)
+	if c.options().Fallback == nil {
+		return rsp, err
+	}
+	failErr, ok := retriesFailed(err)
+	if !ok {
+		return rsp, err
+	}
+	if rsp != nil {
+		rsp.Body.Close()
+	}
+	c.options().Logger.Debugf("FAH[Debug]: retries failed, using fallback: %s", failErr.LastError)
+	return c.options().Fallback(req, failErr)
+}
+
+//resolveURL returns the request with its URL resolved against the BaseURL if it is relative.
+//With an endpoint pool, relative URLs are resolved for every attempt instead.
+func (c *FailAwareHTTPClient) resolveURL(req *http.Request) *http.Request {
+	if c.options().BaseURL == nil || req.URL.IsAbs() || c.pool != nil {
+		return req
+	}
+	resolved := req.WithContext(req.Context())
+	resolved.URL = c.options().BaseURL.ResolveReference(req.URL)
+	resolved.Host = resolved.URL.Host
+	return resolved
+}
+
+//retriesFailed returns the error if the request failed, because all of its retries failed.
+func retriesFailed(err error) (FailAwareHTTPError, bool) {
+	failErr, ok := err.(FailAwareHTTPError)
+	if !ok || (failErr.Kind != KindRetriesExhausted && failErr.Kind != KindRetryBudgetExhausted) {
+		return failErr, false
+	}
+	return failErr, true
+}
+
+func (c *FailAwareHTTPClient) do(originalReq *http.Request) (rsp *http.Response, err error) {
+	//streamed bodies are created again for every attempt instead of being buffered
+	streamed := c.replaysWithGetBody(originalReq)
+	var originalBody []byte
+	var pooled *pooledBody
+	if !streamed && originalReq.Body != nil {
+		pooled, err = bufferBody(originalReq.Body)
+		if err == nil {
+			defer pooled.release()
+			originalBody = pooled.bytes()
+		}
+	}
 	defer func() {
 		if originalReq.Body != nil {
 			originalReq.Body.Close()
@@ -192,48 +695,347 @@ func (c *FailAwareHTTPClient) Do(originalReq *http.Request) (*http.Response, err
 	if err != nil {
 		return nil, err
 	}
+	originalBody, contentEncoding, err := c.compressBody(originalReq, originalBody)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := c.retryPolicy(originalReq)
+	requestCtx := originalReq.Context()
+	if c.options().DualStackFallback {
+		requestCtx = context.WithValue(requestCtx, dialStateKey, &dialState{})
+	}
+	requestID := c.requestID(originalReq)
+	if requestID != "" {
+		requestCtx = WithRequestID(requestCtx, requestID)
+	}
+	trace, _ := originalReq.Context().Value(requestTraceKey).(*requestTrace)
 
 	var lastResponse *http.Response
 	var lastError error
 	retried := 0
 	var errLog []ErrEntry
-	for ; retried < c.options.MaxRetries; retried++ {
+	//backOff is the back off before the current attempt
+	var backOff, waited time.Duration
+	requestStarted := c.options().Clock.Now()
+	fail := func(kind ErrorKind, err error) FailAwareHTTPError {
+		return FailAwareHTTPError{
+			Kind:         kind,
+			Retries:      retried,
+			Errors:       errLog,
+			LastError:    err,
+			RequestID:    requestID,
+			TotalWait:    waited,
+			TotalElapsed: c.options().Clock.Now().Sub(requestStarted),
+		}
+	}
+	//giveUp returns the result of a request that is not retried anymore
+	giveUp := func(kind ErrorKind) (*http.Response, error) {
+		if lastError != nil {
+			return lastResponse, fail(kind, lastError)
+		}
+		if !c.options().ReturnLastResponse {
+			return lastResponse, nil
+		}
+		return lastResponse, fail(kind, fmt.Errorf("%w %d", ErrRetryableStatus, lastResponse.StatusCode))
+	}
+	tokenRefreshed := false
+	refreshToken := false
+	//retrying is set once the request counts against the MaxConcurrentRetries
+	retrying := false
+	defer func() {
+		if retrying {
+			c.stopRetrying()
+		}
+	}()
+	//admitRetry reports whether the request may be retried and takes the retry budgets,
+	//otherwise it returns why not
+	admitRetry := func(host string) (ErrorKind, bool) {
+		if !retrying {
+			if !c.startRetrying(originalReq.Context()) {
+				return KindTooManyRetries, false
+			}
+			retrying = true
+		}
+		//the shared budget first, it must not cost a unit of the budget of the group
+		if !c.takeSharedRetryBudget(originalReq.Context(), host) || !takeRetryBudget(originalReq.Context()) {
+			return KindRetryBudgetExhausted, false
+		}
+		return 0, true
+	}
+	var endpoint *poolEndpoint
+	timer := &retryTimer{clock: c.options().Clock}
+	defer timer.stop()
+	maxAttempts := policy.attempts()
+	defer func() {
+		c.stats.finished(policy, retried, rsp, err)
+		c.emitGiveUp(originalReq, requestID, policy, retried, rsp, err)
+	}()
+	release, admitErr := c.admit(originalReq.Context())
+	switch {
+	case admitErr == ErrQueueTimeout:
+		return nil, fail(KindQueueTimeout, admitErr)
+	case admitErr == ErrShed:
+		return nil, fail(KindShed, admitErr)
+	case admitErr != nil:
+		return nil, fail(KindCanceled, admitErr)
+	}
+	defer release()
+	for ; maxAttempts < 0 || retried < maxAttempts; retried++ {
 
-		if originalBody != nil {
-			reqBody := bytes.NewBuffer(originalBody)
+		if contentEncoding != "" {
 			//just replace the body of the original request
-			originalReq.Body = ioutil.NopCloser(reqBody)
+			originalReq.Body = ioutil.NopCloser(bytes.NewReader(originalBody))
+		} else if pooled != nil {
+			originalReq.Body = pooled.reader()
+		}
+
+		if retried > 0 {
+			requestCtx = context.WithValue(requestCtx, backOffKey, backOff)
+		}
+		if lastResponse != nil {
+			//the response of the previous attempt is replaced by the one of this attempt
+			lastResponse.Body.Close()
 		}
 
-		started := time.Now()
-		lastResponse, lastError = c.httpClient.Do(originalReq)
-		c.options.Logger.Debugf("FAH[Debug]: HTTP response: %#v, error %s", lastResponse, lastError)
-		if c.options.KeepLog {
+		if streamed {
+			body, err := originalReq.GetBody()
+			if err != nil {
+				return nil, fail(KindPrepareFailed, err)
+			}
+			originalReq.Body = body
+		}
+
+		attemptReq := originalReq.WithContext(context.WithValue(requestCtx, attemptKey, retried))
+		//every attempt starts with the original headers, changes of middlewares must not add up
+		attemptReq.Header = originalReq.Header.Clone()
+		if attemptReq.Header == nil {
+			attemptReq.Header = http.Header{}
+		}
+		if c.usesPool(originalReq) {
+			if err := c.refreshPool(originalReq.Context()); err != nil {
+				return nil, fail(KindPrepareFailed, err)
+			}
+			endpoint = c.pool.pick(c.options().Clock.Now(), endpoint)
+			attemptReq.URL = endpoint.resolve(originalReq.URL)
+			attemptReq.Host = endpoint.Host
+		}
+		if err := c.waitGroupRate(originalReq.Context(), timer); err != nil {
+			return nil, fail(KindCanceled, err)
+		}
+		if c.pacer != nil {
+			//the pacing is not part of the timeout of the attempt
+			if err := c.pace(originalReq.Context(), attemptReq.URL.Host, timer); err != nil {
+				return nil, fail(KindCanceled, err)
+			}
+		}
+		attemptCtx, cancel := attemptContext(attemptReq.Context(), policy.Timeout)
+		attemptReq = attemptReq.WithContext(attemptCtx)
+		c.addDefaultHeaders(attemptReq.Header)
+		if contentEncoding != "" {
+			setEncodedBody(attemptReq, originalBody, contentEncoding)
+		}
+		c.expectContinue(attemptReq, int64(len(originalBody)))
+		if requestID != "" && c.options().RequestIDHeader != "" {
+			attemptReq.Header.Set(c.options().RequestIDHeader, requestID)
+		}
+		if retried > 0 && c.options().RetryAttemptHeader != "" {
+			attemptReq.Header.Set(c.options().RetryAttemptHeader, strconv.Itoa(retried))
+		}
+		started := c.options().Clock.Now()
+		lastResponse, lastError = c.sendAttempt(attemptReq, originalBody, retried, refreshToken)
+		refreshToken = false
+		if lastResponse != nil {
+			//the timeout also covers reading the body
+			lastResponse.Body = &cancelOnClose{ReadCloser: lastResponse.Body, cancel: cancel}
+			c.limitResponse(lastResponse)
+		} else {
+			cancel()
+		}
+		if c.pacer != nil && lastResponse != nil {
+			c.pacer.update(attemptReq.URL.Host, lastResponse.Header, c.options().Clock.Now())
+		}
+		if lastError == nil {
+			lastError = c.verifyChecksum(originalReq, lastResponse)
+		}
+		if lastError == nil && c.options().ValidateResponse != nil && !policy.retryable(lastResponse.StatusCode) {
+			lastError = c.validateResponse(lastResponse)
+		}
+		finished := c.options().Clock.Now()
+		failed := lastError != nil || policy.retryable(lastResponse.StatusCode)
+		c.stats.attempt(attemptReq, retried, lastResponse, failed, started, finished)
+		c.emitAttempt(attemptReq, requestID, retried, lastResponse, lastError, started, finished)
+		if endpoint != nil {
+			c.syncEjections(originalReq.Context())
+			c.ejectOutliers(originalReq.Context())
+		}
+		sloExceeded := c.checkSLO(originalReq, retried, started, finished)
+		if trace != nil {
+			trace.retries = retried
+			trace.lastAttempt = finished.Sub(started)
+		}
+		c.options().Logger.Debugf("FAH[Debug]: HTTP response: %#v, error %s", redactResponse(lastResponse, c.redactHeaders), lastError)
+		if c.options().ResponseLogHook != nil && lastResponse != nil {
+			c.options().ResponseLogHook(c.options().Logger, redactResponse(lastResponse, c.redactHeaders))
+		}
+		if c.options().KeepLog {
 			//Debug log response, err result! (if debug enabled)
-			errLog = append(errLog, errEntryNow(lastError, lastResponse, started))
+			reason := failureReason(originalReq.Context(), policy, lastResponse, lastError)
+			var body []byte
+			if reason == ReasonRetryableStatus || reason == ReasonNonRetryableStatus {
+				body = captureBody(lastResponse, c.options().CaptureErrorBodyBytes)
+			}
+			errLog = append(errLog, errEntryFinished(lastError, lastResponse, started, finished, sloExceeded, reason, body))
 		}
 
-		if lastError == nil && lastResponse.StatusCode < 500 && lastResponse.StatusCode != 429 {
+		if lastError == nil && isRequestTooLarge(lastResponse.StatusCode) {
+			return lastResponse, fail(KindRequestTooLarge, ErrRequestTooLarge)
+		}
+
+		if lastError == nil && c.responseTooLarge(lastResponse) {
+			lastResponse.Body.Close()
+			return nil, fail(KindResponseTooLarge, ResponseTooLargeError{Limit: c.options().MaxResponseBytes})
+		}
+
+		if lastError == nil && c.refreshToken(lastResponse, tokenRefreshed) {
+			tokenRefreshed = true
+			if maxAttempts < 0 || retried+1 < maxAttempts {
+				//without a retry the 401 is returned
+				if _, ok := admitRetry(attemptReq.URL.Host); ok {
+					refreshToken = true
+					backOff = 0
+					c.logRetry(attemptReq, retried+1, 0, lastResponse, lastError)
+					c.emitRetry(attemptReq, requestID, retried+1, 0, lastResponse, lastError)
+					continue
+				}
+			}
+		}
+
+		if lastError == nil && !policy.retryable(lastResponse.StatusCode) {
 			if lastError == nil {
 				return lastResponse, nil
 			}
-			return lastResponse, FailAwareHTTPError{Retries: retried, Errors: errLog, LastError: lastError}
+			return lastResponse, fail(KindRetriesExhausted, lastError)
+		}
+
+		var prepareErr prepareError
+		if errors.As(lastError, &prepareErr) {
+			return nil, fail(KindPrepareFailed, prepareErr.err)
 		}
 
 		if errors.Is(lastError, context.Canceled) {
-			return lastResponse, FailAwareHTTPError{Retries: retried, Errors: errLog, LastError: lastError}
+			return lastResponse, fail(KindCanceled, lastError)
+		}
+
+		if ctxErr := originalReq.Context().Err(); ctxErr != nil {
+			//e.g. the deadline of the request passed, no retry can succeed anymore
+			if lastError == nil {
+				lastError = ctxErr
+			}
+			return lastResponse, fail(KindCanceled, lastError)
+		}
+
+		if lastError != nil && !policy.retryableError(lastError) {
+			return lastResponse, fail(KindNotRetryable, lastError)
+		}
+
+		if noRetry(originalReq.Context()) {
+			//no back off after the only attempt
+			return giveUp(KindRetriesExhausted)
+		}
+
+		if !retryAllowed(originalReq, c.options().AllowUnsafeRetry) && !isUnprocessedError(lastError) {
+			return giveUp(KindUnsafeRetry)
+		}
+
+		if maxAttempts >= 0 && retried+1 >= maxAttempts {
+			//no back off after the last attempt, Retries counts it like the end of the loop
+			retried++
+			return giveUp(KindRetriesExhausted)
+		}
+
+		jitter := c.backOff(policy, retried)
+		if lastError == nil && c.options().RateLimitWaits {
+			if wait, ok := rateLimitWait(lastResponse, c.options().Clock.Now()); ok {
+				jitter = policy.capBackOff(wait)
+			}
+		}
+
+		if !c.retryFitsDeadline(originalReq.Context(), jitter, finished.Sub(started)) {
+			return giveUp(KindDeadlineExceeded)
+		}
+
+		if kind, ok := admitRetry(attemptReq.URL.Host); !ok {
+			return giveUp(kind)
+		}
+
+		if c.options().ReResolveOnRetry && lastError != nil {
+			c.options().Logger.Debugf("FAH[Debug]: closing idle connections after transport error")
+			c.httpClient.CloseIdleConnections()
 		}
 
-		jitter := expJitterBackOff(retried, c.options.BackOffDelayFactor)
+		backOff = jitter
+		if len(errLog) > 0 {
+			errLog[len(errLog)-1].backOff = jitter
+		}
+		if err := timer.wait(originalReq.Context(), jitter); err != nil {
+			if lastError == nil {
+				lastError = err
+			}
+			return lastResponse, fail(KindCanceled, lastError)
+		}
+		waited += jitter
+		c.logRetry(attemptReq, retried+1, jitter, lastResponse, lastError)
+		c.emitRetry(attemptReq, requestID, retried+1, jitter, lastResponse, lastError)
+	}
+
+	return giveUp(KindRetriesExhausted)
+}
+
+//retryFitsDeadline reports whether a retry after waiting backOff can complete before the deadline
+//of ctx. The duration of the last attempt is taken as the estimate for the latency of the retry.
+func (c *FailAwareHTTPClient) retryFitsDeadline(ctx context.Context, backOff, latency time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	return c.options().Clock.Now().Add(backOff + latency).Before(deadline)
+}
 
-		<-time.After(jitter)
-		c.options.Logger.Debugf("Retry #%d of request, waited %dms before retry", (retried + 1), jitter/1000000)
+//retryableStatus reports whether a response with the status code is retried.
+//501 and 505 are 5xx, but fail the same way every time.
+func retryableStatus(statusCode int) bool {
+	if statusCode == http.StatusNotImplemented || statusCode == http.StatusHTTPVersionNotSupported {
+		return false
 	}
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooEarly
+}
+
+//isRequestTooLarge reports whether the server refused the request because of its size.
+//Servers often close the connection after these responses, a retry would only repeat this.
+func isRequestTooLarge(statusCode int) bool {
+	return statusCode == http.StatusRequestEntityTooLarge || statusCode == http.StatusRequestHeaderFieldsTooLarge
+}
 
-	if lastError == nil {
-		return lastResponse, nil
+//attemptContext returns the context of an attempt with the timeout, if it is positive.
+func attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
 	}
-	return lastResponse, FailAwareHTTPError{Retries: retried, Errors: errLog, LastError: lastError}
+	return context.WithCancel(ctx)
+}
+
+//cancelOnClose releases the context of an attempt once the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
 }
 
 func readBody(body io.Reader) ([]byte, error) {
@@ -246,15 +1048,3 @@ func readBody(body io.Reader) ([]byte, error) {
 	}
 	return strBody, nil
 }
-
-func expJitterBackOff(retries int, backOffDelayFactor time.Duration) time.Duration {
-	exp := int(1 << uint(retries))
-	ms := exp * int(backOffDelayFactor/time.Millisecond)
-	maxJitter := ms / 3
-	// ms ± rand
-	ms += random.Intn(2*maxJitter) - maxJitter
-	if ms <= 0 {
-		ms = 1
-	}
-	return time.Duration(ms) * time.Millisecond
-}
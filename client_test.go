@@ -2,15 +2,20 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -86,6 +91,96 @@ func TestNoDoRetryOnContextCancel(t *testing.T) {
 	assert.Equal(t, 0, failErr.Retries)
 }
 
+func TestNoDoRetryOnRequestTooLarge(t *testing.T) {
+	for _, statusCode := range []int{413, 431} {
+		port, err := serverWith(statusCode)
+		if err != nil {
+			t.Fatal("unable to start server", err)
+		}
+		url := fmt.Sprintf("http://localhost:%d", port)
+
+		opts := optionsWithMinTimeouts()
+		//an attempt timing out on a slow machine (e.g. with -race) would be retried
+		opts.Timeout = time.Second
+		client := NewClient(opts)
+		req, err := http.NewRequest("GET", url, nil)
+		assert.Nil(t, err)
+
+		rsp, err := client.Do(req)
+		assert.NotNil(t, err)
+		assert.Equal(t, statusCode, rsp.StatusCode)
+
+		failErr := err.(FailAwareHTTPError)
+		assert.Equal(t, KindRequestTooLarge, failErr.Kind)
+		assert.Equal(t, ErrRequestTooLarge, failErr.LastError)
+		assert.Equal(t, 0, failErr.Retries)
+		assert.Equal(t, 1, len(failErr.Errors))
+	}
+}
+
+func TestFallbackOnExhaustedRetries(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.Fallback = func(req *http.Request, err FailAwareHTTPError) (*http.Response, error) {
+		assert.Equal(t, KindRetriesExhausted, err.Kind)
+		assert.Equal(t, 3, err.Retries)
+		return &http.Response{StatusCode: 200, Request: req}, nil
+	}
+	client := NewClient(opts)
+
+	rsp, err := client.Get(nonExistingURL)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Nil(t, rsp.Body.Close(), "a response without body can be closed")
+}
+
+func TestNoFallbackOnNonRetrieableError(t *testing.T) {
+	port, err := serverWith(413)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	opts := optionsWithMinTimeouts()
+	opts.Fallback = func(req *http.Request, err FailAwareHTTPError) (*http.Response, error) {
+		t.Fatal("fallback must not be called")
+		return nil, nil
+	}
+	client := NewClient(opts)
+
+	_, err = client.Get(url)
+	assert.Equal(t, KindRequestTooLarge, err.(FailAwareHTTPError).Kind)
+}
+
+type closeIdleCounter struct {
+	http.RoundTripper
+	closed int
+}
+
+func (t *closeIdleCounter) CloseIdleConnections() {
+	t.closed++
+}
+
+func TestReResolveOnRetryClosesIdleConnections(t *testing.T) {
+	transport := &closeIdleCounter{RoundTripper: http.DefaultTransport}
+	opts := optionsWithMinTimeouts()
+	opts.Transport = transport
+	opts.ReResolveOnRetry = true
+	client := NewClient(opts)
+
+	_, err := client.Get(nonExistingURL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, transport.closed, "before every retry")
+
+	port, err := serverWith(503)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	transport.closed = 0
+	_, err = client.Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, transport.closed)
+}
+
 // Post
 
 func TestRetriesPostOnRetrieableErrorWithTimeCheck(t *testing.T) {
@@ -113,6 +208,58 @@ func TestRetriesPostOnRetrieableErrorWithTimeCheck(t *testing.T) {
 	assertTimeWithDiff(t, currentTime, err2.timestampStarted, 10*time.Millisecond)
 }
 
+func TestRetriesPostWithFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	client := NewClient(opts)
+	_, err := client.Post(nonExistingURL, "application/json", strings.NewReader("dummyBody"))
+	assert.NotNil(t, err)
+
+	failErr := err.(FailAwareHTTPError)
+	assert.Equal(t, 3, failErr.Retries)
+	assert.Equal(t, 2, len(clock.waits))
+	currentTime := fakeClockStart
+	for i, entry := range failErr.Errors {
+		if i > 0 {
+			currentTime = currentTime.Add(clock.waits[i-1])
+		}
+		assert.Equal(t, currentTime, entry.timestampStarted)
+		assert.Equal(t, currentTime, entry.timestampFinished)
+	}
+	assert.Equal(t, currentTime, clock.Now())
+}
+
+func TestNoPostRetryByDefault(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.AllowUnsafeRetry = false
+	client := NewClient(opts)
+	_, err := client.Post(nonExistingURL, "application/json", strings.NewReader("dummyBody"))
+	assert.NotNil(t, err)
+
+	failErr := err.(FailAwareHTTPError)
+	assert.Equal(t, KindUnsafeRetry, failErr.Kind)
+	assert.Equal(t, 0, failErr.Retries)
+	assert.Equal(t, 1, len(failErr.Errors))
+}
+
+func TestPostRetryAllowedPerRequest(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.AllowUnsafeRetry = false
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("POST", nonExistingURL, strings.NewReader("dummyBody"))
+	assert.Nil(t, err)
+	_, err = client.Do(req.WithContext(WithUnsafeRetry(context.Background())))
+	assert.Equal(t, KindRetriesExhausted, err.(FailAwareHTTPError).Kind)
+
+	req, err = http.NewRequest("POST", nonExistingURL, strings.NewReader("dummyBody"))
+	assert.Nil(t, err)
+	req.Header.Set("Idempotency-Key", "4711")
+	_, err = client.Do(req)
+	assert.Equal(t, KindRetriesExhausted, err.(FailAwareHTTPError).Kind)
+}
+
 func TestNoPostRetryOnNonRetrieableError(t *testing.T) {
 	port, err := serverWith(400)
 	if err != nil {
@@ -158,13 +305,8 @@ func TestNoGetRetryOnOk(t *testing.T) {
 
 func TestLogging(t *testing.T) {
 
-	randOrig := random
-	random = rand.New(rand.NewSource(666))
-	defer func() {
-		random = randOrig
-	}()
-
 	opts, logger := optionsWithDummyLogger()
+	opts.RandSource = rand.NewSource(666)
 	client := NewClient(opts)
 	_, err := client.Post(nonExistingURL, "application/json", strings.NewReader("dummyBody"))
 	assert.NotNil(t, err)
@@ -178,7 +320,6 @@ func TestLogging(t *testing.T) {
 		"FAH[Debug]: HTTP response: (*http.Response)(nil), error Post \"http://localhost/doesNotExist\": dial tcp",
 		"Retry #2 of request, waited 10ms before retry",
 		"FAH[Debug]: HTTP response: (*http.Response)(nil), error Post \"http://localhost/doesNotExist\": dial tcp",
-		"Retry #3 of request, waited 17ms before retry",
 	}
 
 	assert.Equal(t, len(expectedLogContains), len(logger.debugLogs))
@@ -188,6 +329,180 @@ func TestLogging(t *testing.T) {
 	}
 }
 
+func TestLogHooksRedactHeaders(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	var loggedReq *http.Request
+	var loggedAttempt int
+	var loggedRsp *http.Response
+	opts, _ := optionsWithDummyLogger()
+	opts.RedactHeaders = []string{"x-api-key"}
+	opts.DefaultHeaders = http.Header{"X-Client": {"test"}}
+	opts.RequestIDHeader = "X-Request-Id"
+	opts.RequestLogHook = func(_ Logger, req *http.Request, attempt int) {
+		loggedReq = req
+		loggedAttempt = attempt
+	}
+	opts.ResponseLogHook = func(_ Logger, rsp *http.Response) {
+		loggedRsp = rsp
+	}
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("GET", url, nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("Accept", "text/plain")
+
+	rsp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+
+	assert.Equal(t, 0, loggedAttempt)
+	assert.Equal(t, "REDACTED", loggedReq.Header.Get("Authorization"))
+	assert.Equal(t, "REDACTED", loggedReq.Header.Get("Cookie"))
+	assert.Equal(t, "REDACTED", loggedReq.Header.Get("X-Api-Key"))
+	assert.Equal(t, "text/plain", loggedReq.Header.Get("Accept"))
+	assert.Equal(t, "test", loggedReq.Header.Get("X-Client"), "the request as it is sent")
+	assert.NotEmpty(t, loggedReq.Header.Get("X-Request-Id"))
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+
+	assert.Equal(t, 200, loggedRsp.StatusCode)
+	assert.Equal(t, "REDACTED", loggedRsp.Request.Header.Get("X-Api-Key"))
+}
+
+func TestStructuredRetryLog(t *testing.T) {
+	logger := &DummyFieldLogger{}
+	opts := optionsWithMinTimeouts()
+	opts.Logger = logger
+	client := NewClient(opts)
+	_, err := client.Post(nonExistingURL, "application/json", strings.NewReader("dummyBody"))
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 2, len(logger.fields), "no retry after the last attempt")
+	for i, fields := range logger.fields {
+		assert.Equal(t, i+1, fields["attempt"])
+		assert.Equal(t, "POST", fields["method"])
+		assert.Equal(t, "localhost", fields["host"])
+		assert.Equal(t, "/doesNotExist", fields["path"])
+		assert.Equal(t, ClassConnectionRefused.String(), fields["error_class"])
+		assert.NotNil(t, fields["wait_ms"])
+		assert.Nil(t, fields["status"])
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	level := func(options FailAwareHTTPOptions) logrus.Level {
+		return NewClient(options).options().Logger.(logrusLogger).GetLevel()
+	}
+	standardLevel := logrus.GetLevel()
+
+	os.Setenv("LOG_LEVEL", "verbose")
+	defer os.Unsetenv("LOG_LEVEL")
+	assert.Equal(t, logrus.ErrorLevel, level(FailAwareHTTPOptions{}), "an unknown level does not panic")
+	os.Setenv("LOG_LEVEL", "info")
+	assert.Equal(t, logrus.InfoLevel, level(FailAwareHTTPOptions{}))
+	assert.Equal(t, logrus.DebugLevel, level(FailAwareHTTPOptions{LogLevel: "debug"}))
+	assert.Equal(t, logrus.ErrorLevel, level(FailAwareHTTPOptions{LogLevel: "verbose"}))
+	assert.Equal(t, standardLevel, logrus.GetLevel(), "the standard logger keeps its level")
+}
+
+func TestCaptureErrorBody(t *testing.T) {
+	var requests int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(503)
+			fmt.Fprint(w, "database unavailable")
+			return
+		}
+		w.WriteHeader(413)
+		fmt.Fprint(w, "payload exceeds 1MB")
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.CaptureErrorBodyBytes = 8
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+
+	entries := err.(FailAwareHTTPError).Errors
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "database", string(entries[0].Body()))
+	assert.Equal(t, "payload ", string(entries[1].Body()))
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "payload exceeds 1MB", string(body), "the returned response keeps its body")
+}
+
+func TestDurationsWithFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	_, err := NewClient(opts).Get(nonExistingURL)
+
+	failErr := err.(FailAwareHTTPError)
+	var waits time.Duration
+	for _, wait := range clock.waits {
+		waits += wait
+	}
+	assert.Equal(t, waits, failErr.TotalWait)
+	assert.Equal(t, clock.Now().Sub(fakeClockStart), failErr.TotalElapsed)
+	assert.Equal(t, time.Duration(0), failErr.Errors[0].Duration(), "the fake clock does not advance during an attempt")
+}
+
+func TestPlannedBackOff(t *testing.T) {
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	var hookBackOffs []time.Duration
+	opts.RequestLogHook = func(logger Logger, req *http.Request, attempt int) {
+		backOff, ok := BackOffFromContext(req.Context())
+		assert.Equal(t, attempt > 0, ok)
+		hookBackOffs = append(hookBackOffs, backOff)
+	}
+	_, err := NewClient(opts).Get(nonExistingURL)
+
+	failErr := err.(FailAwareHTTPError)
+	assert.Equal(t, 3, len(failErr.Errors))
+	for i, entry := range failErr.Errors[:2] {
+		assert.Equal(t, clock.waits[i], entry.BackOff())
+	}
+	assert.Equal(t, time.Duration(0), failErr.Errors[2].BackOff(), "no back off after the last attempt")
+	assert.Equal(t, []time.Duration{0, clock.waits[0], clock.waits[1]}, hookBackOffs)
+}
+
+func TestReturnLastResponse(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace-Id", "trace-1")
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	rsp, err := NewClient(opts).Get(url)
+	assert.Nil(t, err)
+	assert.Equal(t, 503, rsp.StatusCode)
+	rsp.Body.Close()
+
+	opts.ReturnLastResponse = true
+	rsp, err = NewClient(opts).Get(url)
+	assert.Equal(t, 503, rsp.StatusCode)
+	assert.Equal(t, "trace-1", rsp.Header.Get("X-Trace-Id"))
+	rsp.Body.Close()
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindRetriesExhausted, failErr.Kind)
+	assert.True(t, errors.Is(failErr.LastError, ErrRetryableStatus))
+	assert.Equal(t, "gave up on retryable status 503", failErr.LastError.Error())
+}
+
 //Helper
 
 func optionsWithMinTimeouts() FailAwareHTTPOptions {
@@ -196,9 +511,34 @@ func optionsWithMinTimeouts() FailAwareHTTPOptions {
 		Timeout:            10 * time.Millisecond,
 		KeepLog:            true,
 		BackOffDelayFactor: 5 * time.Millisecond,
+		AllowUnsafeRetry:   true, //most tests retry POSTs
 	}
 }
 
+var fakeClockStart = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+//fakeClock does not wait but advances its time by the requested duration.
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: fakeClockStart}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
 type DummyLogger struct {
 	debugLogs []string
 }
@@ -207,6 +547,15 @@ func (l *DummyLogger) Debugf(format string, v ...interface{}) {
 	l.debugLogs = append(l.debugLogs, fmt.Sprintf(format, v...))
 }
 
+type DummyFieldLogger struct {
+	DummyLogger
+	fields []Fields
+}
+
+func (l *DummyFieldLogger) DebugFields(msg string, fields Fields) {
+	l.fields = append(l.fields, fields)
+}
+
 //also with MinTimeouts
 func optionsWithDummyLogger() (FailAwareHTTPOptions, *DummyLogger) {
 	logger := DummyLogger{}
@@ -221,14 +570,18 @@ func assertTimeWithDiff(t *testing.T, expected, actual time.Time, diffMax time.D
 }
 
 func serverWith(statusCode int) (int, error) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	return serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(statusCode)
 		_, err := w.Write([]byte(fmt.Sprintf("%d status code", statusCode)))
 		if err != nil {
 			panic(err)
 		}
 	})
+}
+
+func serverWithHandler(handler http.HandlerFunc) (int, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
 	l, err := net.Listen("tcp", ":0")
 	if err != nil {
 		return -1, fmt.Errorf("unable to secure listener %v", err)
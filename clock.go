@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"time"
+)
+
+//Clock is the source of time of the client. It is used for the timestamps of the
+//error log and for waiting between retries. Inject a custom Clock with
+//FailAwareHTTPOptions.Clock to test retry behaviour without real waits.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+//realClock is the default Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//retryTimer waits between the retries of a request. With the real clock one timer is reused
+//for all retries of the request instead of allocating one per retry with time.After.
+type retryTimer struct {
+	clock Clock
+	timer *time.Timer
+}
+
+//wait waits for d or until the context is done, then it returns the error of the context.
+func (t *retryTimer) wait(ctx context.Context, d time.Duration) error {
+	var fired <-chan time.Time
+	if _, ok := t.clock.(realClock); ok {
+		if t.timer == nil {
+			t.timer = time.NewTimer(d)
+		} else {
+			t.timer.Reset(d)
+		}
+		fired = t.timer.C
+	} else {
+		fired = t.clock.After(d)
+	}
+	select {
+	case <-fired:
+		return nil
+	case <-ctx.Done():
+		t.stop()
+		return ctx.Err()
+	}
+}
+
+//stop stops the timer, a Reset afterwards must not see a stale expiry.
+func (t *retryTimer) stop() {
+	if t.timer != nil && !t.timer.Stop() {
+		select {
+		case <-t.timer.C:
+		default:
+		}
+	}
+}
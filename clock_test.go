@@ -0,0 +1,42 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTimerReused(t *testing.T) {
+	timer := &retryTimer{clock: realClock{}}
+	defer timer.stop()
+	assert.Nil(t, timer.wait(context.Background(), time.Millisecond))
+	first := timer.timer
+	assert.Nil(t, timer.wait(context.Background(), time.Millisecond))
+	assert.True(t, first == timer.timer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, timer.wait(ctx, time.Hour))
+	started := time.Now()
+	assert.Nil(t, timer.wait(context.Background(), time.Millisecond), "no stale expiry after the stop")
+	assert.True(t, time.Since(started) >= time.Millisecond)
+}
+
+func TestCancelDuringBackOff(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.BackOffStrategy = BackOffConstant
+	opts.BackOffDelayFactor = time.Hour
+	opts.DisableJitter = true
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	started := time.Now()
+	_, err := NewClient(opts).Do(mustRequestWithContext(t, ctx, nonExistingURL))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindCanceled, failErr.Kind)
+	assert.Equal(t, 1, len(failErr.Errors))
+	assert.True(t, time.Since(started) < time.Second, "the back off ends with the context")
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var defaultCoalesceHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie"}
+
+//flight is a GET in progress whose result is shared with identical GETs.
+type flight struct {
+	done  chan struct{}
+	entry *cacheEntry
+	err   error
+}
+
+//flightGroup tracks the GETs in flight, like golang.org/x/sync/singleflight.
+type flightGroup struct {
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+func (c *FailAwareHTTPClient) coalesceKey(req *http.Request) string {
+	headers := c.options().CoalesceHeaders
+	if headers == nil {
+		headers = defaultCoalesceHeaders
+	}
+	var key strings.Builder
+	key.WriteString(cacheKey(req))
+	for _, name := range headers {
+		key.WriteString("\n")
+		key.WriteString(http.CanonicalHeaderKey(name))
+		key.WriteString(": ")
+		key.WriteString(strings.Join(req.Header.Values(name), ", "))
+	}
+	return key.String()
+}
+
+//coalesce calls send only once for identical concurrent GETs. The response is buffered
+//and every caller gets its own copy. The result (and a cancellation) of the first
+//caller is shared with all others.
+func (c *FailAwareHTTPClient) coalesce(req *http.Request, send func() (*http.Response, error)) (*http.Response, error) {
+	if !c.options().CoalesceRequests || req.Method != "GET" {
+		return send()
+	}
+	key := c.coalesceKey(req)
+
+	c.flights.mutex.Lock()
+	if f, ok := c.flights.flights[key]; ok {
+		c.flights.mutex.Unlock()
+		c.options().Logger.Debugf("FAH[Debug]: waiting for the result of in-flight request %s", req.URL)
+		<-f.done
+		return f.result(req)
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights.flights[key] = f
+	c.flights.mutex.Unlock()
+
+	defer func() {
+		c.flights.mutex.Lock()
+		delete(c.flights.flights, key)
+		c.flights.mutex.Unlock()
+		close(f.done)
+	}()
+
+	rsp, err := send()
+	f.err = err
+	if rsp != nil {
+		body, readErr := readBody(rsp.Body)
+		rsp.Body.Close()
+		if readErr != nil && err == nil {
+			f.err = readErr
+		}
+		f.entry = &cacheEntry{statusCode: rsp.StatusCode, header: rsp.Header, body: body}
+	}
+	return f.result(req)
+}
+
+func (f *flight) result(req *http.Request) (*http.Response, error) {
+	if f.entry == nil {
+		return nil, f.err
+	}
+	return f.entry.response(req), f.err
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesceIdenticalGets(t *testing.T) {
+	var hits int32
+	//the first GETs are answered once the other 8 joined them
+	logger := &joinLogger{all: make(chan struct{}), expected: 8}
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		select {
+		case <-logger.all:
+		case <-time.After(5 * time.Second):
+		}
+		w.Write([]byte("shared " + r.Header.Get("Accept")))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/resource", port)
+
+	opts := NewDefaultOptions()
+	opts.CoalesceRequests = true
+	opts.Logger = logger
+	client := NewClient(opts)
+
+	var wg sync.WaitGroup
+	bodies := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		accept := "text/plain"
+		if i%2 == 1 {
+			accept = "application/json"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest("GET", url, nil)
+			assert.Nil(t, err)
+			req.Header.Set("Accept", accept)
+			rsp, err := client.Do(req)
+			assert.Nil(t, err)
+			body, err := ioutil.ReadAll(rsp.Body)
+			assert.Nil(t, err)
+			bodies <- string(body)
+		}()
+	}
+
+	wg.Wait()
+	close(bodies)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	counts := map[string]int{}
+	for body := range bodies {
+		counts[body]++
+	}
+	assert.Equal(t, map[string]int{"shared text/plain": 5, "shared application/json": 5}, counts)
+}
+
+//joinLogger closes all once the expected number of GETs joined an identical GET in flight.
+type joinLogger struct {
+	joined   int32
+	expected int32
+	all      chan struct{}
+}
+
+func (l *joinLogger) Debugf(format string, v ...interface{}) {
+	if strings.HasPrefix(format, "FAH[Debug]: waiting for the result of in-flight request") && atomic.AddInt32(&l.joined, 1) == l.expected {
+		close(l.all)
+	}
+}
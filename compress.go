@@ -0,0 +1,104 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//compressBody compresses the buffered body of a request once, so all attempts replay the
+//compressed bytes. It returns the body unchanged and no encoding if the request is not
+//compressed: CompressRequests is not set, the body is empty or already encoded.
+func (c *FailAwareHTTPClient) compressBody(req *http.Request, body []byte) ([]byte, string, error) {
+	if !c.options().CompressRequests || len(body) == 0 || req.Header.Get("Content-Encoding") != "" {
+		return body, "", nil
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return compressed.Bytes(), "gzip", nil
+}
+
+//setEncodedBody declares the compressed body of an attempt.
+func setEncodedBody(req *http.Request, body []byte, encoding string) {
+	req.Header.Set("Content-Encoding", encoding)
+	req.ContentLength = int64(len(body))
+	//redirects send the body again
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+//ContentDecoder decodes a response body with a content encoding, see
+//FailAwareHTTPOptions.ContentDecoders.
+type ContentDecoder func(body io.Reader) (io.ReadCloser, error)
+
+func gzipDecoder(body io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(body)
+}
+
+//acceptEncodings advertises the encodings of the ContentDecoders and gzip, unless the request
+//asks for encodings itself. It reports whether the response has to be decoded by the client.
+func (c *FailAwareHTTPClient) acceptEncodings(req *http.Request) bool {
+	if len(c.options().ContentDecoders) == 0 || req.Header.Get("Accept-Encoding") != "" || req.Method == "HEAD" {
+		return false
+	}
+	encodings := make([]string, 0, len(c.options().ContentDecoders)+1)
+	for encoding := range c.options().ContentDecoders {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+	if _, ok := c.options().ContentDecoders["gzip"]; !ok {
+		encodings = append(encodings, "gzip")
+	}
+	req.Header.Set("Accept-Encoding", strings.Join(encodings, ", "))
+	return true
+}
+
+//decodeResponse decodes the body of the response with the decoder of its Content-Encoding,
+//like net/http does for gzip.
+func (c *FailAwareHTTPClient) decodeResponse(rsp *http.Response) (*http.Response, error) {
+	encoding := strings.ToLower(strings.TrimSpace(rsp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return rsp, nil
+	}
+	decoder, ok := c.options().ContentDecoders[encoding]
+	if !ok && encoding == "gzip" {
+		decoder, ok = gzipDecoder, true
+	}
+	if !ok {
+		return rsp, nil
+	}
+	decoded, err := decoder(rsp.Body)
+	if err != nil {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("decoding %s response: %w", encoding, err)
+	}
+	rsp.Body = decodedBody{ReadCloser: decoded, encoded: rsp.Body}
+	rsp.Header.Del("Content-Encoding")
+	rsp.Header.Del("Content-Length")
+	rsp.ContentLength = -1
+	rsp.Uncompressed = true
+	return rsp, nil
+}
+
+//decodedBody closes the decoder and the encoded body.
+type decodedBody struct {
+	io.ReadCloser
+	encoded io.ReadCloser
+}
+
+func (b decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.encoded.Close()
+}
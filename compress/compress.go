@@ -0,0 +1,39 @@
+//Package compress provides zstd and brotli decoders for the ContentDecoders of a
+//FailAwareHTTP client:
+//
+//	options.ContentDecoders = compress.Decoders()
+//
+//The package is a module of its own, so the compression dependencies are only pulled in
+//by its users.
+package compress
+
+import (
+	"io"
+	"io/ioutil"
+
+	fah "github.com/Ragnaroek/failawarehttp"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+//Decoders returns the zstd and brotli decoders by their content encoding.
+func Decoders() map[string]fah.ContentDecoder {
+	return map[string]fah.ContentDecoder{
+		"zstd": Zstd,
+		"br":   Brotli,
+	}
+}
+
+//Zstd decodes a zstd encoded body.
+func Zstd(body io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+//Brotli decodes a brotli encoded body.
+func Brotli(body io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(body)), nil
+}
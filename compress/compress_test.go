@@ -0,0 +1,67 @@
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fah "github.com/Ragnaroek/failawarehttp"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func zstdEncode(t *testing.T, content string) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll([]byte(content), nil)
+}
+
+func brotliEncode(t *testing.T, content string) []byte {
+	var encoded bytes.Buffer
+	writer := brotli.NewWriter(&encoded)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+func TestDecoders(t *testing.T) {
+	var accepted string
+	encoded := map[string][]byte{
+		"zstd": zstdEncode(t, "zstd content"),
+		"br":   brotliEncode(t, "brotli content"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+		encoding := r.URL.Path[1:]
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(encoded[encoding])
+	}))
+	defer server.Close()
+
+	client := fah.NewClient(fah.FailAwareHTTPOptions{
+		MaxRetries:         1,
+		Timeout:            time.Second,
+		BackOffDelayFactor: time.Millisecond,
+		ContentDecoders:    Decoders(),
+	})
+	for encoding, expected := range map[string]string{"zstd": "zstd content", "br": "brotli content"} {
+		rsp, err := client.Get(server.URL + "/" + encoding)
+		assert.Nil(t, err)
+		body, err := ioutil.ReadAll(rsp.Body)
+		assert.Nil(t, err)
+		assert.Nil(t, rsp.Body.Close())
+		assert.Equal(t, expected, string(body))
+	}
+	assert.Equal(t, "br, zstd, gzip", accepted)
+}
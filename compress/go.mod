@@ -0,0 +1,20 @@
+module github.com/Ragnaroek/failawarehttp/compress
+
+//Develop against the local root module with a workspace: go work init . ./compress ./http3
+
+go 1.22
+
+require (
+	github.com/Ragnaroek/failawarehttp v0.0.0-20261016172651-e9b12768d384
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
+	github.com/stretchr/testify v1.6.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/sirupsen/logrus v1.6.0 // indirect
+	golang.org/x/sys v0.0.0-20200625212154-ddb9806d33ae // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressRequests(t *testing.T) {
+	var bodies []string
+	var lengths []int64
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		lengths = append(lengths, r.ContentLength)
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, "plain:"+string(body))
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		assert.Nil(t, err)
+		body, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(503)
+		}
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	payload := strings.Repeat("compressible ", 1000)
+
+	opts := optionsWithMinTimeouts()
+	opts.CompressRequests = true
+	client := NewClient(opts)
+	rsp, err := client.Post(url, "text/plain", strings.NewReader(payload))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Equal(t, []string{payload, payload}, bodies, "the retry replays the compressed body")
+	assert.True(t, lengths[0] < int64(len(payload))/10, lengths[0])
+	assert.Equal(t, lengths[0], lengths[1])
+
+	req, err := http.NewRequest("POST", url, strings.NewReader("encoded"))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Encoding", "identity")
+	_, err = client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "plain:encoded", bodies[2], "bodies with an encoding are sent as they are")
+}
+
+func TestContentDecoders(t *testing.T) {
+	var accepted []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		accepted = append(accepted, r.Header.Get("Accept-Encoding"))
+		switch r.URL.Path {
+		case "/base64":
+			w.Header().Set("Content-Encoding", "x-base64")
+			fmt.Fprint(w, base64.StdEncoding.EncodeToString([]byte("decoded")))
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			fmt.Fprint(writer, "gunzipped")
+			writer.Close()
+		default:
+			fmt.Fprint(w, "plain")
+		}
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would send the request again
+	opts.Timeout = time.Second
+	opts.ContentDecoders = map[string]ContentDecoder{
+		"x-base64": func(body io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, body)), nil
+		},
+	}
+	client := NewClient(opts)
+	for path, expected := range map[string]string{"/base64": "decoded", "/gzip": "gunzipped", "/plain": "plain"} {
+		rsp, err := client.Get(url + path)
+		assert.Nil(t, err)
+		body, err := ioutil.ReadAll(rsp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(body))
+		assert.Equal(t, "", rsp.Header.Get("Content-Encoding"))
+	}
+	assert.Equal(t, []string{"x-base64, gzip", "x-base64, gzip", "x-base64, gzip"}, accepted)
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//policyConfig is a RetryPolicy in a config file, durations are strings like "1.5s".
+type policyConfig struct {
+	MaxAttempts        int    `json:"max_attempts" yaml:"max_attempts"`
+	MaxRetries         int    `json:"max_retries" yaml:"max_retries"`
+	Timeout            string `json:"timeout" yaml:"timeout"`
+	BackOffDelayFactor string `json:"back_off_delay_factor" yaml:"back_off_delay_factor"`
+	MaxBackOff         string `json:"max_back_off" yaml:"max_back_off"`
+	//BackOffStrategy is exponential, constant, linear or fibonacci.
+	BackOffStrategy string `json:"back_off_strategy" yaml:"back_off_strategy"`
+	//Jitter is relative or full.
+	Jitter            string `json:"jitter" yaml:"jitter"`
+	DisableJitter     bool   `json:"disable_jitter" yaml:"disable_jitter"`
+	RetryImmediately  bool   `json:"retry_immediately" yaml:"retry_immediately"`
+	RetryableStatuses []int  `json:"retryable_statuses" yaml:"retryable_statuses"`
+}
+
+//optionsConfig are the FailAwareHTTPOptions that can be configured in a file or the environment.
+type optionsConfig struct {
+	policyConfig          `yaml:",inline"`
+	BaseURL               string                  `json:"base_url" yaml:"base_url"`
+	DefaultHeaders        map[string]string       `json:"default_headers" yaml:"default_headers"`
+	AllowUnsafeRetry      bool                    `json:"allow_unsafe_retry" yaml:"allow_unsafe_retry"`
+	MaxRedirects          int                     `json:"max_redirects" yaml:"max_redirects"`
+	MaxResponseBytes      int64                   `json:"max_response_bytes" yaml:"max_response_bytes"`
+	MaxConcurrentRequests int                     `json:"max_concurrent_requests" yaml:"max_concurrent_requests"`
+	MaxQueueWait          string                  `json:"max_queue_wait" yaml:"max_queue_wait"`
+	MaxConcurrentRetries  int                     `json:"max_concurrent_retries" yaml:"max_concurrent_retries"`
+	LogLevel              string                  `json:"log_level" yaml:"log_level"`
+	HostPolicies          map[string]policyConfig `json:"host_policies" yaml:"host_policies"`
+	MethodPolicies        map[string]policyConfig `json:"method_policies" yaml:"method_policies"`
+}
+
+var backOffStrategies = map[string]BackOffStrategy{
+	"exponential": BackOffExponential,
+	"constant":    BackOffConstant,
+	"linear":      BackOffLinear,
+	"fibonacci":   BackOffFibonacci,
+}
+
+var jitterModes = map[string]JitterMode{
+	"relative": JitterRelative,
+	"full":     JitterFull,
+}
+
+//OptionsFromFile reads the options from a YAML (.yaml, .yml) or JSON (.json) file, so the
+//retries can be tuned by the configuration of a deployment. The keys are the names of the
+//options in snake case, durations are strings like "1.5s":
+//
+//	max_attempts: 4
+//	timeout: 2s
+//	back_off_strategy: linear
+//	host_policies:
+//	  payments:8443:
+//	    max_attempts: 2
+//
+//Options that are not set keep their defaults, see NewClient. Only options that are
+//plain values can be configured, the others (e.g. the Logger) are set in code.
+func OptionsFromFile(path string) (FailAwareHTTPOptions, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return FailAwareHTTPOptions{}, err
+	}
+	var config optionsConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &config)
+	case ".json":
+		err = json.Unmarshal(content, &config)
+	default:
+		return FailAwareHTTPOptions{}, fmt.Errorf("unsupported config file %s, expected .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return FailAwareHTTPOptions{}, fmt.Errorf("parsing config file %s failed: %w", path, err)
+	}
+	return config.options()
+}
+
+//OptionsFromEnv reads the options from the environment variables with the prefix. The
+//variables are named like the keys of OptionsFromFile in upper case, e.g. FAH_MAX_ATTEMPTS
+//and FAH_TIMEOUT for the prefix FAH_. Lists are comma separated, maps (DEFAULT_HEADERS,
+//HOST_POLICIES and METHOD_POLICIES) are JSON objects.
+func OptionsFromEnv(prefix string) (FailAwareHTTPOptions, error) {
+	var config optionsConfig
+	if err := configFromEnv(prefix, reflect.ValueOf(&config).Elem()); err != nil {
+		return FailAwareHTTPOptions{}, err
+	}
+	return config.options()
+}
+
+//configFromEnv sets the fields of the config struct from the environment variables.
+func configFromEnv(prefix string, config reflect.Value) error {
+	for i := 0; i < config.NumField(); i++ {
+		field := config.Type().Field(i)
+		if field.Anonymous {
+			if err := configFromEnv(prefix, config.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name := prefix + strings.ToUpper(field.Tag.Get("json"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setConfigField(config.Field(i), value); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Slice:
+		var statuses []int
+		for _, status := range strings.Split(value, ",") {
+			parsed, err := strconv.Atoi(strings.TrimSpace(status))
+			if err != nil {
+				return err
+			}
+			statuses = append(statuses, parsed)
+		}
+		field.Set(reflect.ValueOf(statuses))
+	case reflect.Map:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+	return nil
+}
+
+func (c optionsConfig) options() (FailAwareHTTPOptions, error) {
+	if len(c.RetryableStatuses) > 0 {
+		return FailAwareHTTPOptions{}, fmt.Errorf("retryable_statuses can only be set in host_policies and method_policies")
+	}
+	policy, err := c.policyConfig.policy()
+	if err != nil {
+		return FailAwareHTTPOptions{}, err
+	}
+	options := FailAwareHTTPOptions{
+		MaxRetries:            policy.MaxRetries,
+		MaxAttempts:           policy.MaxAttempts,
+		Timeout:               policy.Timeout,
+		BackOffDelayFactor:    policy.BackOffDelayFactor,
+		MaxBackOff:            policy.MaxBackOff,
+		BackOffStrategy:       policy.BackOffStrategy,
+		Jitter:                policy.Jitter,
+		DisableJitter:         policy.DisableJitter,
+		RetryImmediately:      policy.RetryImmediately,
+		AllowUnsafeRetry:      c.AllowUnsafeRetry,
+		MaxRedirects:          c.MaxRedirects,
+		MaxResponseBytes:      c.MaxResponseBytes,
+		MaxConcurrentRequests: c.MaxConcurrentRequests,
+		MaxConcurrentRetries:  c.MaxConcurrentRetries,
+		LogLevel:              c.LogLevel,
+	}
+	if options.MaxQueueWait, err = parseConfigDuration("max_queue_wait", c.MaxQueueWait); err != nil {
+		return FailAwareHTTPOptions{}, err
+	}
+	if c.BaseURL != "" {
+		options.BaseURL, err = url.Parse(c.BaseURL)
+		if err != nil {
+			return FailAwareHTTPOptions{}, fmt.Errorf("invalid base_url: %w", err)
+		}
+	}
+	if len(c.DefaultHeaders) > 0 {
+		options.DefaultHeaders = http.Header{}
+		for name, value := range c.DefaultHeaders {
+			options.DefaultHeaders.Set(name, value)
+		}
+	}
+	if options.HostPolicies, err = policies(c.HostPolicies); err != nil {
+		return FailAwareHTTPOptions{}, fmt.Errorf("invalid host_policies: %w", err)
+	}
+	if options.MethodPolicies, err = policies(c.MethodPolicies); err != nil {
+		return FailAwareHTTPOptions{}, fmt.Errorf("invalid method_policies: %w", err)
+	}
+	return options, nil
+}
+
+func policies(configs map[string]policyConfig) (map[string]RetryPolicy, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]RetryPolicy, len(configs))
+	for key, config := range configs {
+		policy, err := config.policy()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		result[key] = policy
+	}
+	return result, nil
+}
+
+func (c policyConfig) policy() (RetryPolicy, error) {
+	policy := RetryPolicy{
+		MaxAttempts:       c.MaxAttempts,
+		MaxRetries:        c.MaxRetries,
+		DisableJitter:     c.DisableJitter,
+		RetryImmediately:  c.RetryImmediately,
+		RetryableStatuses: c.RetryableStatuses,
+	}
+	var err error
+	if policy.Timeout, err = parseConfigDuration("timeout", c.Timeout); err != nil {
+		return RetryPolicy{}, err
+	}
+	if policy.BackOffDelayFactor, err = parseConfigDuration("back_off_delay_factor", c.BackOffDelayFactor); err != nil {
+		return RetryPolicy{}, err
+	}
+	if policy.MaxBackOff, err = parseConfigDuration("max_back_off", c.MaxBackOff); err != nil {
+		return RetryPolicy{}, err
+	}
+	if c.BackOffStrategy != "" {
+		strategy, ok := backOffStrategies[strings.ToLower(c.BackOffStrategy)]
+		if !ok {
+			return RetryPolicy{}, fmt.Errorf("invalid back_off_strategy %q", c.BackOffStrategy)
+		}
+		policy.BackOffStrategy = strategy
+	}
+	if c.Jitter != "" {
+		jitter, ok := jitterModes[strings.ToLower(c.Jitter)]
+		if !ok {
+			return RetryPolicy{}, fmt.Errorf("invalid jitter %q", c.Jitter)
+		}
+		policy.Jitter = jitter
+	}
+	return policy, nil
+}
+
+func parseConfigDuration(key, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return duration, nil
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "fah-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOptionsFromFile(t *testing.T) {
+	path := writeConfig(t, "fah.yaml", `
+max_attempts: 4
+timeout: 2s
+back_off_delay_factor: 100ms
+back_off_strategy: linear
+jitter: full
+base_url: http://api:8080/v1/
+default_headers:
+  X-Client: billing
+host_policies:
+  payments:8443:
+    max_attempts: 2
+    retryable_statuses: [502, 503]
+method_policies:
+  POST:
+    disable_jitter: true
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	options, err := OptionsFromFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, options.MaxAttempts)
+	assert.Equal(t, 2*time.Second, options.Timeout)
+	assert.Equal(t, 100*time.Millisecond, options.BackOffDelayFactor)
+	assert.Equal(t, BackOffLinear, options.BackOffStrategy)
+	assert.Equal(t, JitterFull, options.Jitter)
+	assert.Equal(t, "http://api:8080/v1/", options.BaseURL.String())
+	assert.Equal(t, "billing", options.DefaultHeaders.Get("X-Client"))
+	assert.Equal(t, RetryPolicy{MaxAttempts: 2, RetryableStatuses: []int{502, 503}}, options.HostPolicies["payments:8443"])
+	assert.True(t, options.MethodPolicies["POST"].DisableJitter)
+
+	jsonPath := writeConfig(t, "fah.json", `{"max_retries": 5, "max_back_off": "1m", "host_policies": {"api": {"timeout": "500ms"}}}`)
+	defer os.RemoveAll(filepath.Dir(jsonPath))
+	options, err = OptionsFromFile(jsonPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, options.MaxRetries)
+	assert.Equal(t, time.Minute, options.MaxBackOff)
+	assert.Equal(t, 500*time.Millisecond, options.HostPolicies["api"].Timeout)
+}
+
+func TestOptionsFromFileInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"duration.yaml":    "timeout: 2 seconds",
+		"strategy.yaml":    "host_policies: {api: {back_off_strategy: quadratic}}",
+		"statuses.json":    `{"retryable_statuses": [503]}`,
+		"malformed.json":   `{"max_attempts": `,
+		"unsupported.toml": "max_attempts = 3",
+	} {
+		path := writeConfig(t, name, content)
+		_, err := OptionsFromFile(path)
+		assert.NotNil(t, err, name)
+		os.RemoveAll(filepath.Dir(path))
+	}
+	_, err := OptionsFromFile("/nonexisting/fah.yaml")
+	assert.NotNil(t, err)
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"FAH_MAX_ATTEMPTS":       "3",
+		"FAH_TIMEOUT":            "250ms",
+		"FAH_DISABLE_JITTER":     "true",
+		"FAH_MAX_RESPONSE_BYTES": "1048576",
+		"FAH_MAX_QUEUE_WAIT":     "2s",
+		"FAH_HOST_POLICIES":      `{"api:8080": {"max_attempts": 5, "retryable_statuses": [429]}}`,
+		"OTHER_MAX_ATTEMPTS":     "7",
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	options, err := OptionsFromEnv("FAH_")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, options.MaxAttempts)
+	assert.Equal(t, 250*time.Millisecond, options.Timeout)
+	assert.True(t, options.DisableJitter)
+	assert.Equal(t, int64(1048576), options.MaxResponseBytes)
+	assert.Equal(t, 2*time.Second, options.MaxQueueWait)
+	assert.Equal(t, RetryPolicy{MaxAttempts: 5, RetryableStatuses: []int{429}}, options.HostPolicies["api:8080"])
+
+	os.Setenv("FAH_MAX_ATTEMPTS", "three")
+	_, err = OptionsFromEnv("FAH_")
+	assert.NotNil(t, err)
+}
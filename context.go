@@ -0,0 +1,87 @@
+package http
+
+import (
+	"context"
+	"time"
+)
+
+//contextKey is the type of the keys of all values this package stores in request contexts.
+type contextKey int
+
+const (
+	retryBudgetKey contextKey = iota
+	retryPolicyKey
+	requestTraceKey
+	attemptKey
+	unsafeRetryKey
+	dialStateKey
+	unixSocketKey
+	requestIDKey
+	checksumKey
+	streamedBodyKey
+	backOffKey
+	noRetryKey
+	priorityKey
+	groupRateKey
+)
+
+//AttemptFromContext returns the number of the attempt (starting at 0) of a request sent
+//by the client. Transports and middlewares can use it with the context of the request.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(attemptKey).(int)
+	return attempt, ok
+}
+
+//BackOffFromContext returns the back off the client waited before the retry of a request
+//sent with the context, e.g. for middlewares, PrepareRetry and the RequestLogHook. Not set
+//for the first attempt.
+func BackOffFromContext(ctx context.Context) (time.Duration, bool) {
+	backOff, ok := ctx.Value(backOffKey).(time.Duration)
+	return backOff, ok
+}
+
+//WithRequestID returns a context with the ID of a request, it is sent as
+//FailAwareHTTPOptions.RequestIDHeader by all requests with this context.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+//RequestIDFromContext returns the request ID of the context.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok
+}
+
+//WithAttemptTimeout returns a context that overrides the timeout of a single attempt
+//(see RetryPolicy.Timeout) for all requests with this context. A negative timeout
+//disables the timeout of the attempts.
+func WithAttemptTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	policy, _ := ctx.Value(retryPolicyKey).(RetryPolicy)
+	policy.Timeout = timeout
+	return context.WithValue(ctx, retryPolicyKey, policy)
+}
+
+//WithNoRetry returns a context that makes all requests with this context send exactly one
+//attempt, e.g. on latency critical paths. A failed attempt is returned at once, without
+//waiting any back off.
+func WithNoRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey, true)
+}
+
+//noRetry reports whether the requests with the context are not retried, see WithNoRetry.
+func noRetry(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetryKey).(bool)
+	return disabled
+}
+
+//WithPriority returns a context with the priority of all requests with this context,
+//see FailAwareHTTPOptions.MaxConcurrentRequests and GroupOptions.MaxRetries.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey, priority)
+}
+
+//PriorityFromContext returns the priority of the context, PriorityNormal if not set.
+func PriorityFromContext(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityKey).(Priority)
+	return priority
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+var defaultClientMutex sync.Mutex
+var defaultClient *FailAwareHTTPClient
+
+//DefaultClient returns the client of the package level functions Get, Post and Do. It is
+//created with defaultOptions on first use unless set with SetDefaultClient.
+func DefaultClient() *FailAwareHTTPClient {
+	defaultClientMutex.Lock()
+	defer defaultClientMutex.Unlock()
+	if defaultClient == nil {
+		defaultClient = NewDefaultClient()
+	}
+	return defaultClient
+}
+
+//SetDefaultClient replaces the client of the package level functions Get, Post and Do.
+//nil restores a client with defaultOptions.
+func SetDefaultClient(client *FailAwareHTTPClient) {
+	defaultClientMutex.Lock()
+	defer defaultClientMutex.Unlock()
+	defaultClient = client
+}
+
+//Get does a fail-aware Get request with the DefaultClient, like http.Get.
+func Get(url string) (*http.Response, error) {
+	return DefaultClient().Get(url)
+}
+
+//Post does a fail-aware Post request with the DefaultClient, like http.Post.
+func Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return DefaultClient().Post(url, contentType, body)
+}
+
+//Do sends the request with the DefaultClient, like http.DefaultClient.Do.
+func Do(req *http.Request) (*http.Response, error) {
+	return DefaultClient().Do(req)
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultClient(t *testing.T) {
+	defer SetDefaultClient(nil)
+	assert.True(t, DefaultClient() == DefaultClient())
+
+	var methods []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	client := NewClient(optionsWithMinTimeouts())
+	SetDefaultClient(client)
+	assert.True(t, client == DefaultClient())
+
+	rsp, err := Get(url + "/get")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	rsp, err = Post(url+"/post", "text/plain", strings.NewReader("body"))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	req, _ := http.NewRequest("PUT", url+"/put", nil)
+	rsp, err = Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Equal(t, []string{"GET", "POST", "PUT"}, methods)
+
+	SetDefaultClient(nil)
+	assert.False(t, client == DefaultClient())
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//DNSCacheStats are the counters of the DNS cache of a client.
+type DNSCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+//dnsCache caches resolved addresses for a fixed TTL, the resolver of the standard library
+//does not expose the TTLs of the records. A host is evicted if no connection to any of its
+//addresses could be established.
+type dnsCache struct {
+	ttl        time.Duration
+	clock      Clock
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+
+	mutex   sync.Mutex
+	entries map[string]dnsEntry
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+func newDNSCache(ttl time.Duration, clock Clock) *dnsCache {
+	return &dnsCache{
+		ttl:        ttl,
+		clock:      clock,
+		lookupHost: net.DefaultResolver.LookupHost,
+		entries:    make(map[string]dnsEntry),
+	}
+}
+
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := d.clock.Now()
+	d.mutex.Lock()
+	entry, ok := d.entries[host]
+	d.mutex.Unlock()
+	if ok && now.Before(entry.expires) {
+		atomic.AddInt64(&d.hits, 1)
+		return entry.addrs, nil
+	}
+
+	atomic.AddInt64(&d.misses, 1)
+	addrs, err := d.lookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	d.mutex.Lock()
+	d.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(d.ttl)}
+	d.mutex.Unlock()
+	return addrs, nil
+}
+
+func (d *dnsCache) evict(host string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.entries[host]; ok {
+		delete(d.entries, host)
+		atomic.AddInt64(&d.evictions, 1)
+	}
+}
+
+func (d *dnsCache) stats() DNSCacheStats {
+	return DNSCacheStats{
+		Hits:      atomic.LoadInt64(&d.hits),
+		Misses:    atomic.LoadInt64(&d.misses),
+		Evictions: atomic.LoadInt64(&d.evictions),
+	}
+}
+
+//dialContext dials the cached addresses of the host one after the other.
+func (d *dnsCache) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := d.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			if !matchesFamily(network, ip) {
+				continue
+			}
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+		}
+		if ctx.Err() == nil {
+			//the addresses are not to blame if the caller gave up
+			d.evict(host)
+		}
+		return nil, lastErr
+	}
+}
+
+func matchesFamily(network, ip string) bool {
+	parsed := net.ParseIP(ip)
+	switch network {
+	case "tcp4":
+		return parsed.To4() != nil
+	case "tcp6":
+		return parsed.To4() == nil
+	}
+	return true
+}
+
+//DNSCacheStats returns the counters of the DNS cache, all zero if it is not enabled.
+func (c *FailAwareHTTPClient) DNSCacheStats() DNSCacheStats {
+	if c.dnsCache == nil {
+		return DNSCacheStats{}
+	}
+	return c.dnsCache.stats()
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSCacheHitsAndExpiry(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	clock := newFakeClock()
+	opts := NewDefaultOptions()
+	opts.DNSCacheTTL = time.Minute
+	opts.Clock = clock
+	client := NewClient(opts)
+
+	//a new connection for every request, closing idle connections races with returning them
+	get := func() {
+		req, err := http.NewRequest("GET", url, nil)
+		assert.Nil(t, err)
+		req.Close = true
+		rsp, err := client.Do(req)
+		assert.Nil(t, err)
+		rsp.Body.Close()
+	}
+	get()
+	get()
+	assert.Equal(t, DNSCacheStats{Hits: 1, Misses: 1}, client.DNSCacheStats())
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	get()
+	assert.Equal(t, DNSCacheStats{Hits: 1, Misses: 2}, client.DNSCacheStats())
+}
+
+func TestDNSCacheEvictsAfterConnectFailure(t *testing.T) {
+	cache := newDNSCache(time.Minute, newFakeClock())
+	cache.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	}
+	dial := cache.dialContext((&net.Dialer{}).DialContext)
+
+	//port 1 is not listening
+	_, err := dial(context.Background(), "tcp", "failing.example:1")
+	assert.NotNil(t, err)
+	assert.Equal(t, DNSCacheStats{Misses: 1, Evictions: 1}, cache.stats())
+
+	_, err = dial(context.Background(), "tcp", "failing.example:1")
+	assert.NotNil(t, err)
+	assert.Equal(t, DNSCacheStats{Misses: 2, Evictions: 2}, cache.stats())
+}
+
+func TestDNSCacheKeepsEntryOnCanceledDial(t *testing.T) {
+	cache := newDNSCache(time.Minute, newFakeClock())
+	cache.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	dial := cache.dialContext(func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+		cancel()
+		return nil, dialCtx.Err()
+	})
+
+	_, err := dial(ctx, "tcp", "canceled.example:80")
+	assert.NotNil(t, err)
+	assert.Equal(t, DNSCacheStats{Misses: 1}, cache.stats())
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+//dialState remembers across the attempts of a request which address family failed to connect.
+type dialState struct {
+	mutex        sync.Mutex
+	failedFamily string
+}
+
+func (s *dialState) network(network string) string {
+	if network != "tcp" {
+		return network
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch s.failedFamily {
+	case "tcp6":
+		return "tcp4"
+	case "tcp4":
+		return "tcp6"
+	}
+	return network
+}
+
+func (s *dialState) failed(family string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failedFamily = family
+}
+
+//failedFamily returns the address family of the address a dial error refers to.
+func failedFamily(network string, err error) string {
+	if network == "tcp4" || network == "tcp6" {
+		return network
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return ""
+	}
+	var ip net.IP
+	switch addr := opErr.Addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.IPAddr:
+		ip = addr.IP
+	}
+	if ip == nil {
+		return ""
+	}
+	if ip.To4() != nil {
+		return "tcp4"
+	}
+	return "tcp6"
+}
+
+//dualStackDial switches to the other address family for the next attempt of a request,
+//if an attempt failed to connect over IPv6 or IPv4.
+func dualStackDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		state, ok := ctx.Value(dialStateKey).(*dialState)
+		if !ok {
+			return dial(ctx, network, addr)
+		}
+		network = state.network(network)
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			if family := failedFamily(network, err); family != "" {
+				state.failed(family)
+			}
+		}
+		return conn, err
+	}
+}
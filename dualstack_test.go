@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDualStackDialSwitchesFamily(t *testing.T) {
+	var networks []string
+	dial := dualStackDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		networks = append(networks, network)
+		if network == "tcp" {
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 80}, Err: errors.New("network is unreachable")}
+		}
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+	})
+
+	ctx := context.WithValue(context.Background(), dialStateKey, &dialState{})
+	for i := 0; i < 3; i++ {
+		_, err := dial(ctx, "tcp", "example.com:80")
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, []string{"tcp", "tcp4", "tcp6"}, networks)
+
+	networks = nil
+	_, err := dial(context.Background(), "tcp", "example.com:80")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"tcp"}, networks)
+}
+
+func TestMatchesFamily(t *testing.T) {
+	assert.True(t, matchesFamily("tcp4", "127.0.0.1"))
+	assert.False(t, matchesFamily("tcp4", "::1"))
+	assert.True(t, matchesFamily("tcp6", "::1"))
+	assert.True(t, matchesFamily("tcp", "::1"))
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+//ErrorClass is the class of a transport error, see ClassifyError.
+type ErrorClass int
+
+const (
+	//ClassOther errors that fit no other class.
+	ClassOther ErrorClass = iota
+	//ClassConnectionRefused nothing listens on the address.
+	ClassConnectionRefused
+	//ClassConnectionReset the connection was reset or closed by the server.
+	ClassConnectionReset
+	//ClassTimeout dialing, the TLS handshake or the attempt timed out.
+	ClassTimeout
+	//ClassDNSNotFound the host does not exist (NXDOMAIN).
+	ClassDNSNotFound
+	//ClassTLS the TLS handshake failed.
+	ClassTLS
+	//ClassCertificate the certificate of the server is not valid (unknown authority,
+	//wrong host name, expired). These errors are never retried.
+	ClassCertificate
+	//ClassUnprocessed the server refused the request before processing it, an HTTP/2
+	//REFUSED_STREAM or a graceful GOAWAY (RFC 7540 8.1.4). These errors are retried even for
+	//non-idempotent methods.
+	ClassUnprocessed
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassOther:
+		return "other"
+	case ClassConnectionRefused:
+		return "connection refused"
+	case ClassConnectionReset:
+		return "connection reset"
+	case ClassTimeout:
+		return "timeout"
+	case ClassDNSNotFound:
+		return "dns not found"
+	case ClassTLS:
+		return "tls"
+	case ClassCertificate:
+		return "certificate"
+	case ClassUnprocessed:
+		return "unprocessed"
+	}
+	return fmt.Sprintf("ErrorClass(%d)", int(c))
+}
+
+//FailureReason is why an attempt was considered a failure, see ErrEntry.Reason.
+type FailureReason int
+
+const (
+	//ReasonNone the attempt succeeded.
+	ReasonNone FailureReason = iota
+	//ReasonTransportError the attempt got no response, see ErrEntry.ErrorClass.
+	ReasonTransportError
+	//ReasonRetryableStatus the response has a status that is retried, e.g. 503.
+	ReasonRetryableStatus
+	//ReasonNonRetryableStatus the response has an error status (4xx, 5xx) that is not retried.
+	ReasonNonRetryableStatus
+	//ReasonCanceled the context of the request was canceled or its deadline passed.
+	ReasonCanceled
+	//ReasonInvalidResponse the response was rejected by the ResponseValidator.
+	ReasonInvalidResponse
+)
+
+func (r FailureReason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonTransportError:
+		return "transport error"
+	case ReasonRetryableStatus:
+		return "retryable status"
+	case ReasonNonRetryableStatus:
+		return "non-retryable status"
+	case ReasonCanceled:
+		return "canceled"
+	case ReasonInvalidResponse:
+		return "invalid response"
+	}
+	return fmt.Sprintf("FailureReason(%d)", int(r))
+}
+
+//failureReason classifies the result of an attempt, ctx is the context of the request.
+func failureReason(ctx context.Context, policy RetryPolicy, rsp *http.Response, err error) FailureReason {
+	switch {
+	case errors.Is(err, context.Canceled) || (err != nil && ctx.Err() != nil):
+		return ReasonCanceled
+	case errors.As(err, &InvalidResponseError{}):
+		return ReasonInvalidResponse
+	case err != nil:
+		return ReasonTransportError
+	case policy.retryable(rsp.StatusCode):
+		return ReasonRetryableStatus
+	case rsp.StatusCode >= 400:
+		return ReasonNonRetryableStatus
+	}
+	return ReasonNone
+}
+
+//ClassifyError returns the class of a transport error.
+func ClassifyError(err error) ErrorClass {
+	if isUnprocessedError(err) {
+		return ClassUnprocessed
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ClassConnectionRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ClassConnectionReset
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return ClassDNSNotFound
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ClassTimeout
+	}
+	if isCertificateError(err) {
+		return ClassCertificate
+	}
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return ClassTLS
+	}
+	return ClassOther
+}
+
+//unprocessedMessages are the messages of the HTTP/2 errors of net/http for requests the server
+//did not process. net/http does not export the types of these errors.
+var unprocessedMessages = []string{
+	"REFUSED_STREAM",
+	"http2: Transport received Server's graceful shutdown GOAWAY",
+}
+
+//isUnprocessedError reports whether the server refused the request before processing it.
+//A GOAWAY that closed the connection does not count, the request may have been processed.
+func isUnprocessedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, unprocessed := range unprocessedMessages {
+		if strings.Contains(msg, unprocessed) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCertificateError(err error) bool {
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	_, err := http.Get(nonExistingURL)
+	assert.Equal(t, ClassConnectionRefused, ClassifyError(err))
+
+	dnsErr := &url.Error{Op: "Get", URL: "http://unknown.invalid", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Name: "unknown.invalid", IsNotFound: true}}}
+	assert.Equal(t, ClassDNSNotFound, ClassifyError(dnsErr))
+
+	assert.Equal(t, ClassTimeout, ClassifyError(&url.Error{Op: "Get", Err: context.DeadlineExceeded}))
+	assert.Equal(t, ClassOther, ClassifyError(errors.New("something else")))
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err = http.Get(server.URL)
+	assert.Equal(t, ClassCertificate, ClassifyError(err))
+
+	reset := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer reset.Close()
+	_, err = http.Get(reset.URL)
+	assert.Equal(t, ClassConnectionReset, ClassifyError(err))
+}
+
+func TestRetryableErrors(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.RetryableErrors = []ErrorClass{ClassTimeout, ClassConnectionReset}
+	_, err := NewClient(opts).Get(nonExistingURL)
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindNotRetryable, failErr.Kind)
+	assert.Equal(t, 0, failErr.Retries)
+
+	opts.RetryableErrors = []ErrorClass{ClassConnectionRefused}
+	_, err = NewClient(opts).Get(nonExistingURL)
+	failErr, ok = err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindRetriesExhausted, failErr.Kind)
+	assert.Equal(t, 3, failErr.Retries)
+}
+
+func TestCertificateErrorNotRetried(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	opts := optionsWithMinTimeouts()
+	opts.KeepLog = true
+	opts.RetryableErrors = []ErrorClass{ClassCertificate}
+	//a handshake timing out on a slow machine (e.g. with -race) would not be a certificate error
+	opts.Timeout = time.Second
+	_, err := NewClient(opts).Get(server.URL)
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindNotRetryable, failErr.Kind)
+	assert.Equal(t, 0, failErr.Retries)
+	assert.Equal(t, 1, len(failErr.Errors))
+	assert.Equal(t, ClassCertificate, ClassifyError(failErr.LastError))
+}
+
+func TestUnprocessedErrorRetriedForUnsafeMethods(t *testing.T) {
+	refused := errors.New("http2: stream error: stream ID 3; REFUSED_STREAM")
+	assert.Equal(t, ClassUnprocessed, ClassifyError(&url.Error{Op: "Post", Err: refused}))
+	assert.Equal(t, ClassUnprocessed, ClassifyError(errors.New("http2: Transport received Server's graceful shutdown GOAWAY")))
+	assert.Equal(t, ClassOther, ClassifyError(errors.New("http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug=\"\"")),
+		"the request may have been processed before the connection was closed")
+
+	attempts := 0
+	opts := optionsWithMinTimeouts()
+	opts.AllowUnsafeRetry = false
+	opts.Transport = RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, refused
+		}
+		return &http.Response{StatusCode: 201, Body: http.NoBody, Request: req}, nil
+	})
+	rsp, err := NewClient(opts).Post("http://localhost/orders", "text/plain", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, rsp.StatusCode)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestFailureReason(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be classified as a timeout
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	_, err := client.Get(nonExistingURL)
+	entries := err.(FailAwareHTTPError).Errors
+	assert.Equal(t, ReasonTransportError, entries[0].Reason())
+	assert.Equal(t, ClassConnectionRefused, entries[0].ErrorClass())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Do(mustRequestWithContext(t, ctx, server.URL))
+	assert.Equal(t, ReasonCanceled, err.(FailAwareHTTPError).Errors[0].Reason())
+
+	assert.Equal(t, ReasonRetryableStatus, failureReason(context.Background(), RetryPolicy{}, &http.Response{StatusCode: 503}, nil))
+	assert.Equal(t, ReasonNonRetryableStatus, failureReason(context.Background(), RetryPolicy{}, &http.Response{StatusCode: 404}, nil))
+	assert.Equal(t, ReasonNone, failureReason(context.Background(), RetryPolicy{}, &http.Response{StatusCode: 200}, nil))
+	assert.Equal(t, "retryable status", ReasonRetryableStatus.String())
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//errEntryJSON is the JSON document of an ErrEntry.
+type errEntryJSON struct {
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+	DurationMS  float64   `json:"duration_ms"`
+	BackOffMS   float64   `json:"back_off_ms,omitempty"`
+	Status      int       `json:"status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ErrorClass  string    `json:"error_class,omitempty"`
+	Reason      string    `json:"reason"`
+	SLOExceeded bool      `json:"slo_exceeded,omitempty"`
+	Body        string    `json:"body,omitempty"`
+}
+
+//failAwareHTTPErrorJSON is the JSON document of a FailAwareHTTPError.
+type failAwareHTTPErrorJSON struct {
+	Kind           string     `json:"kind"`
+	Retries        int        `json:"retries"`
+	RequestID      string     `json:"request_id,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	TotalWaitMS    float64    `json:"total_wait_ms"`
+	TotalElapsedMS float64    `json:"total_elapsed_ms"`
+	Attempts       []ErrEntry `json:"attempts"`
+}
+
+//MarshalJSON encodes the entry as a JSON object with the timestamps (RFC 3339), the duration,
+//the back off after the attempt, the status of the response, the error and its class, the
+//failure reason and the captured body.
+func (e ErrEntry) MarshalJSON() ([]byte, error) {
+	document := errEntryJSON{
+		Started:     e.timestampStarted,
+		Finished:    e.timestampFinished,
+		DurationMS:  milliseconds(e.Duration()),
+		BackOffMS:   milliseconds(e.backOff),
+		Reason:      e.reason.String(),
+		SLOExceeded: e.sloExceeded,
+		Body:        string(e.body),
+	}
+	if e.response != nil {
+		document.Status = e.response.StatusCode
+	}
+	if e.err != nil {
+		document.Error = e.err.Error()
+		document.ErrorClass = e.ErrorClass().String()
+	}
+	return json.Marshal(document)
+}
+
+//MarshalJSON encodes the error as a JSON object, e.g. for structured logs or incident tickets:
+//
+//	{"kind":"retries exhausted","retries":3,"last_error":"...","attempts":[{"started":...}]}
+//
+//The attempts are the Errors, empty without KeepLog.
+func (e FailAwareHTTPError) MarshalJSON() ([]byte, error) {
+	document := failAwareHTTPErrorJSON{
+		Kind:           e.Kind.String(),
+		Retries:        e.Retries,
+		RequestID:      e.RequestID,
+		TotalWaitMS:    milliseconds(e.TotalWait),
+		TotalElapsedMS: milliseconds(e.TotalElapsed),
+		Attempts:       e.Errors,
+	}
+	if e.LastError != nil {
+		document.LastError = e.LastError.Error()
+	}
+	if document.Attempts == nil {
+		document.Attempts = []ErrEntry{}
+	}
+	return json.Marshal(document)
+}
+
+//milliseconds returns the duration in (fractional) milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailAwareHTTPErrorJSON(t *testing.T) {
+	started := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	refused := errors.New("dial tcp 127.0.0.1:80: connect: connection refused")
+	err := FailAwareHTTPError{
+		Kind:         KindRetriesExhausted,
+		Retries:      2,
+		RequestID:    "req-1",
+		LastError:    refused,
+		TotalWait:    950 * time.Millisecond,
+		TotalElapsed: 1005 * time.Millisecond,
+		Errors: []ErrEntry{
+			withBackOff(errEntryFinished(nil, &http.Response{StatusCode: 503}, started, started.Add(20*time.Millisecond), true, ReasonRetryableStatus, []byte("maintenance")), 950*time.Millisecond),
+			errEntryFinished(refused, nil, started.Add(time.Second), started.Add(time.Second+5*time.Millisecond), false, ReasonTransportError, nil),
+		},
+	}
+
+	encoded, marshalErr := json.Marshal(err)
+	assert.Nil(t, marshalErr)
+	assert.JSONEq(t, `{
+		"kind": "retries exhausted",
+		"retries": 2,
+		"request_id": "req-1",
+		"last_error": "dial tcp 127.0.0.1:80: connect: connection refused",
+		"total_wait_ms": 950,
+		"total_elapsed_ms": 1005,
+		"attempts": [
+			{"started": "2020-06-01T12:00:00Z", "finished": "2020-06-01T12:00:00.02Z", "duration_ms": 20, "back_off_ms": 950, "status": 503,
+			 "reason": "retryable status", "slo_exceeded": true, "body": "maintenance"},
+			{"started": "2020-06-01T12:00:01Z", "finished": "2020-06-01T12:00:01.005Z", "duration_ms": 5,
+			 "error": "dial tcp 127.0.0.1:80: connect: connection refused", "error_class": "other",
+			 "reason": "transport error"}
+		]
+	}`, string(encoded))
+
+	encoded, marshalErr = json.Marshal(FailAwareHTTPError{Kind: KindCanceled})
+	assert.Nil(t, marshalErr)
+	assert.JSONEq(t, `{"kind": "canceled", "retries": 0, "total_wait_ms": 0, "total_elapsed_ms": 0, "attempts": []}`, string(encoded))
+}
+
+func withBackOff(entry ErrEntry, backOff time.Duration) ErrEntry {
+	entry.backOff = backOff
+	return entry
+}
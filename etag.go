@@ -0,0 +1,84 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//ErrConcurrentModification is returned by UpdateWithETag if the resource was modified
+//concurrently in every round.
+var ErrConcurrentModification = errors.New("resource modified concurrently")
+
+//ErrNoETag is returned by UpdateWithETag if the server did not send an ETag for the resource.
+var ErrNoETag = errors.New("resource has no ETag")
+
+const defaultETagRounds = 3
+
+//UpdateWithETag implements optimistic concurrency: it GETs the resource, passes the body
+//to modify and PUTs the result with If-Match set to the ETag of the fetched version.
+//If the server answers 412 Precondition Failed the resource is fetched again, for at most
+//maxRounds rounds (3 if maxRounds <= 0). Each GET and PUT is retried as usual.
+func (c *FailAwareHTTPClient) UpdateWithETag(ctx context.Context, url, contentType string, modify func(current []byte) ([]byte, error), maxRounds int) (*http.Response, error) {
+	if maxRounds <= 0 {
+		maxRounds = defaultETagRounds
+	}
+
+	for round := 0; round < maxRounds; round++ {
+		current, etag, err := c.fetchWithETag(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := modify(current)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest("PUT", url, bytes.NewReader(updated))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-Match", etag)
+
+		rsp, err := c.Do(req)
+		if err != nil {
+			return rsp, err
+		}
+		if rsp.StatusCode != http.StatusPreconditionFailed {
+			return rsp, nil
+		}
+		rsp.Body.Close()
+		c.options().Logger.Debugf("FAH[Debug]: %s modified concurrently, round %d", url, round+1)
+	}
+	return nil, ErrConcurrentModification
+}
+
+func (c *FailAwareHTTPClient) fetchWithETag(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	rsp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: unexpected status %d", url, rsp.StatusCode)
+	}
+	etag := rsp.Header.Get("ETag")
+	if etag == "" {
+		return nil, "", ErrNoETag
+	}
+	body, err := readBody(rsp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, etag, nil
+}
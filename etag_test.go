@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedDocument struct {
+	mutex    sync.Mutex
+	version  int
+	body     string
+	conflict int //number of PUTs that lose against a concurrent writer
+}
+
+func (d *versionedDocument) handle(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	etag := fmt.Sprintf("\"v%d\"", d.version)
+	switch r.Method {
+	case "GET":
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(d.body))
+	case "PUT":
+		if d.conflict > 0 {
+			d.conflict--
+			d.version++
+			d.body += "+other"
+		}
+		if r.Header.Get("If-Match") != fmt.Sprintf("\"v%d\"", d.version) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		d.body = string(body)
+		d.version++
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestUpdateWithETagRefetchesOnConflict(t *testing.T) {
+	doc := &versionedDocument{body: "doc", conflict: 1}
+	port, err := serverWithHandler(doc.handle)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	client := NewDefaultClient()
+	rsp, err := client.UpdateWithETag(context.Background(), url, "text/plain", func(current []byte) ([]byte, error) {
+		return append(current, []byte("+mine")...), nil
+	}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rsp.StatusCode)
+	assert.Equal(t, "doc+other+mine", doc.body)
+}
+
+func TestUpdateWithETagGivesUpAfterMaxRounds(t *testing.T) {
+	doc := &versionedDocument{body: "doc", conflict: 2}
+	port, err := serverWithHandler(doc.handle)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	client := NewDefaultClient()
+	_, err = client.UpdateWithETag(context.Background(), url, "text/plain", func(current []byte) ([]byte, error) {
+		return current, nil
+	}, 2)
+	assert.Equal(t, ErrConcurrentModification, err)
+}
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//defaultEventBufferSize is the capacity of the event channel if EventBufferSize is not set.
+const defaultEventBufferSize = 256
+
+//EventType is the kind of a RetryEvent.
+type EventType int
+
+const (
+	//EventAttempt an attempt of a request finished, with a response or an error.
+	EventAttempt EventType = iota
+	//EventRetry a request is retried after waiting the back off.
+	EventRetry
+	//EventGiveUp a request failed, either with an error or with a retryable status after
+	//its last attempt.
+	EventGiveUp
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAttempt:
+		return "attempt"
+	case EventRetry:
+		return "retry"
+	case EventGiveUp:
+		return "give up"
+	}
+	return "unknown"
+}
+
+//RetryEvent describes the retry activity of a request, see FailAwareHTTPClient.Events.
+type RetryEvent struct {
+	Type EventType
+	Time time.Time
+	//Method, Host and Path of the request, the query is left out as it may contain secrets.
+	Method string
+	Host   string
+	Path   string
+	//RequestID of the request, empty if it has none.
+	RequestID string
+	//Attempt is the number of the attempt (starting at 0). For EventRetry the attempt that
+	//is sent next, for EventGiveUp the last attempt.
+	Attempt int
+	//StatusCode of the response of the attempt, 0 if it got none.
+	StatusCode int
+	//Err of the attempt, for EventGiveUp the FailAwareHTTPError of the request.
+	Err error
+	//Wait is the back off before the retry, only for EventRetry.
+	Wait time.Duration
+	//Latency of the attempt, only for EventAttempt.
+	Latency time.Duration
+	//Kind why the request failed, only for EventGiveUp.
+	Kind ErrorKind
+}
+
+//retryEvents delivers the events of a client. The channel is created by the first call of
+//Events, before no events are built.
+type retryEvents struct {
+	mutex   sync.Mutex
+	events  chan RetryEvent
+	size    int
+	dropped int64
+}
+
+func newRetryEvents(size int) *retryEvents {
+	if size <= 0 {
+		size = defaultEventBufferSize
+	}
+	return &retryEvents{size: size}
+}
+
+//channel returns the channel of the stream, nil if nobody asked for the events yet.
+func (s *retryEvents) channel() chan RetryEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.events
+}
+
+//emit sends the event without blocking, it is dropped if the channel is full.
+func (s *retryEvents) emit(events chan RetryEvent, event RetryEvent) {
+	select {
+	case events <- event:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+//Events returns a channel of the attempts, retries and give ups of the requests of the
+//client, e.g. for a monitoring agent. The channel is buffered (EventBufferSize) and never
+//blocks a request: events that do not fit are dropped and counted, see DroppedEvents.
+//All calls return the same channel, it is never closed.
+func (c *FailAwareHTTPClient) Events() <-chan RetryEvent {
+	s := c.events
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.events == nil {
+		s.events = make(chan RetryEvent, s.size)
+	}
+	return s.events
+}
+
+//DroppedEvents returns the number of events dropped because the channel of Events was full.
+func (c *FailAwareHTTPClient) DroppedEvents() int64 {
+	return atomic.LoadInt64(&c.events.dropped)
+}
+
+//requestEvent returns an event of the request.
+func requestEvent(eventType EventType, req *http.Request, requestID string, attempt int, rsp *http.Response, err error, now time.Time) RetryEvent {
+	event := RetryEvent{
+		Type:      eventType,
+		Time:      now,
+		Method:    req.Method,
+		Host:      req.URL.Host,
+		Path:      req.URL.Path,
+		RequestID: requestID,
+		Attempt:   attempt,
+		Err:       err,
+	}
+	if rsp != nil {
+		event.StatusCode = rsp.StatusCode
+	}
+	return event
+}
+
+//emitAttempt emits an EventAttempt, if there is a consumer of the events.
+func (c *FailAwareHTTPClient) emitAttempt(req *http.Request, requestID string, attempt int, rsp *http.Response, err error, started, finished time.Time) {
+	events := c.events.channel()
+	if events == nil {
+		return
+	}
+	event := requestEvent(EventAttempt, req, requestID, attempt, rsp, err, finished)
+	event.Latency = finished.Sub(started)
+	c.events.emit(events, event)
+}
+
+//emitRetry emits an EventRetry, if there is a consumer of the events.
+func (c *FailAwareHTTPClient) emitRetry(req *http.Request, requestID string, attempt int, wait time.Duration, rsp *http.Response, err error) {
+	events := c.events.channel()
+	if events == nil {
+		return
+	}
+	event := requestEvent(EventRetry, req, requestID, attempt, rsp, err, c.options().Clock.Now())
+	event.Wait = wait
+	c.events.emit(events, event)
+}
+
+//emitGiveUp emits an EventGiveUp if the request failed and there is a consumer of the events.
+func (c *FailAwareHTTPClient) emitGiveUp(req *http.Request, requestID string, policy RetryPolicy, retried int, rsp *http.Response, err error) {
+	if err == nil && (rsp == nil || !policy.retryable(rsp.StatusCode)) {
+		return
+	}
+	var failErr FailAwareHTTPError
+	if err != nil && !errors.As(err, &failErr) {
+		//failed before the first attempt, e.g. reading the body
+		return
+	}
+	events := c.events.channel()
+	if events == nil {
+		return
+	}
+	attempt := retried
+	if maxAttempts := policy.attempts(); maxAttempts >= 0 && attempt >= maxAttempts {
+		//the loop ended after the last attempt
+		attempt = maxAttempts - 1
+	}
+	event := requestEvent(EventGiveUp, req, requestID, attempt, rsp, err, c.options().Clock.Now())
+	event.Kind = failErr.Kind
+	c.events.emit(events, event)
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receivedEvents(events <-chan RetryEvent) []RetryEvent {
+	var received []RetryEvent
+	for {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	client := NewClient(optionsWithMinTimeouts())
+	rsp, err := client.Get(fmt.Sprintf("http://localhost:%d/events?token=secret", port))
+	assert.Nil(t, err)
+	assert.Equal(t, 503, rsp.StatusCode)
+	assert.Equal(t, int64(0), client.DroppedEvents(), "no events without a consumer")
+
+	events := client.Events()
+	assert.True(t, events == client.Events())
+	client.Get(fmt.Sprintf("http://localhost:%d/events?token=secret", port))
+	received := receivedEvents(events)
+
+	var types []EventType
+	var attempts []int
+	for _, event := range received {
+		types = append(types, event.Type)
+		attempts = append(attempts, event.Attempt)
+		assert.Equal(t, "GET", event.Method)
+		assert.Equal(t, "/events", event.Path)
+		assert.Equal(t, 503, event.StatusCode)
+	}
+	assert.Equal(t, []EventType{EventAttempt, EventRetry, EventAttempt, EventRetry, EventAttempt, EventGiveUp}, types)
+	assert.Equal(t, []int{0, 1, 1, 2, 2, 2}, attempts)
+	assert.True(t, received[0].Latency > 0)
+	assert.True(t, received[1].Wait > 0)
+	assert.Equal(t, KindRetriesExhausted, received[5].Kind)
+
+	_, err = client.Get(nonExistingURL)
+	received = receivedEvents(events)
+	giveUp := received[len(received)-1]
+	assert.Equal(t, EventGiveUp, giveUp.Type)
+	assert.Equal(t, err, giveUp.Err)
+	assert.Equal(t, 0, giveUp.StatusCode)
+	assert.NotNil(t, received[0].Err)
+}
+
+func TestEventsDropped(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.EventBufferSize = 2
+	client := NewClient(opts)
+	events := client.Events()
+	client.Get(nonExistingURL)
+
+	assert.Equal(t, 2, len(receivedEvents(events)))
+	assert.Equal(t, int64(4), client.DroppedEvents())
+}
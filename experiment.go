@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//requestTrace collects what happened during a single Do call for callers within this package.
+type requestTrace struct {
+	retries     int
+	lastAttempt time.Duration
+}
+
+//Experiment splits the traffic of a client between a control and a candidate RetryPolicy
+//and collects comparative statistics, so a policy change can be validated before it is
+//rolled out to all requests. Create one with FailAwareHTTPClient.NewExperiment.
+type Experiment struct {
+	client         *FailAwareHTTPClient
+	control        RetryPolicy
+	candidate      RetryPolicy
+	candidateShare float64
+
+	mutex          sync.Mutex
+	controlStats   ExperimentArmStats
+	candidateStats ExperimentArmStats
+}
+
+//ExperimentArmStats are the statistics of one policy of an Experiment.
+type ExperimentArmStats struct {
+	Requests  int64
+	Successes int64
+	Retries   int64
+	//AddedLatency is the sum of the time spent in failed attempts and backoff waits.
+	AddedLatency time.Duration
+}
+
+//SuccessRate returns the fraction of requests that ended with a non-retrieable response.
+func (s ExperimentArmStats) SuccessRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Requests)
+}
+
+//MeanAddedLatency returns the average latency added by retries per request.
+func (s ExperimentArmStats) MeanAddedLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.AddedLatency / time.Duration(s.Requests)
+}
+
+//ExperimentResults compares the control and the candidate policy of an Experiment.
+type ExperimentResults struct {
+	Control   ExperimentArmStats
+	Candidate ExperimentArmStats
+}
+
+//NewExperiment creates an Experiment that sends the share candidateShare (0 to 1) of
+//the requests with the candidate policy and the rest with the control policy.
+func (c *FailAwareHTTPClient) NewExperiment(control, candidate RetryPolicy, candidateShare float64) *Experiment {
+	return &Experiment{
+		client:         c,
+		control:        control,
+		candidate:      candidate,
+		candidateShare: candidateShare,
+	}
+}
+
+//Do sends the request with the policy of a randomly selected arm of the experiment.
+func (e *Experiment) Do(req *http.Request) (*http.Response, error) {
+	policy := e.control
+	stats := &e.controlStats
+	if e.client.random.Float64() < e.candidateShare {
+		policy = e.candidate
+		stats = &e.candidateStats
+	}
+
+	trace := &requestTrace{}
+	ctx := context.WithValue(req.Context(), retryPolicyKey, policy)
+	ctx = context.WithValue(ctx, requestTraceKey, trace)
+
+	experimentReq := req.WithContext(ctx)
+	effective := e.client.retryPolicy(experimentReq)
+
+	started := e.client.options().Clock.Now()
+	rsp, err := e.client.Do(experimentReq)
+	elapsed := e.client.options().Clock.Now().Sub(started)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	stats.Requests++
+	if err == nil && !effective.retryable(rsp.StatusCode) {
+		stats.Successes++
+	}
+	stats.Retries += int64(trace.retries)
+	stats.AddedLatency += elapsed - trace.lastAttempt
+	return rsp, err
+}
+
+//Results returns a snapshot of the statistics collected so far.
+func (e *Experiment) Results() ExperimentResults {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return ExperimentResults{Control: e.controlStats, Candidate: e.candidateStats}
+}
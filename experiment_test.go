@@ -0,0 +1,57 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExperimentComparesPolicies(t *testing.T) {
+	client := NewClient(optionsWithMinTimeouts())
+	control := RetryPolicy{MaxRetries: 3}
+	candidate := RetryPolicy{MaxRetries: 2}
+
+	allCandidate := client.NewExperiment(control, candidate, 1)
+	allControl := client.NewExperiment(control, candidate, 0)
+	for _, experiment := range []*Experiment{allCandidate, allControl} {
+		req, err := http.NewRequest("GET", nonExistingURL, nil)
+		assert.Nil(t, err)
+		_, err = experiment.Do(req)
+		assert.NotNil(t, err)
+	}
+
+	results := allCandidate.Results()
+	assert.Equal(t, int64(0), results.Control.Requests)
+	assert.Equal(t, int64(1), results.Candidate.Requests)
+	assert.Equal(t, int64(1), results.Candidate.Retries)
+	assert.Equal(t, 0.0, results.Candidate.SuccessRate())
+	assert.True(t, results.Candidate.MeanAddedLatency() > 0)
+
+	results = allControl.Results()
+	assert.Equal(t, int64(1), results.Control.Requests)
+	assert.Equal(t, int64(2), results.Control.Retries)
+	assert.Equal(t, int64(0), results.Candidate.Requests)
+}
+
+func TestExperimentCountsSuccess(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	experiment := NewDefaultClient().NewExperiment(RetryPolicy{}, RetryPolicy{}, 0.5)
+	for i := 0; i < 10; i++ {
+		req, err := http.NewRequest("GET", url, nil)
+		assert.Nil(t, err)
+		rsp, err := experiment.Do(req)
+		assert.Nil(t, err)
+		rsp.Body.Close()
+	}
+
+	results := experiment.Results()
+	assert.Equal(t, int64(10), results.Control.Requests+results.Candidate.Requests)
+	assert.Equal(t, int64(10), results.Control.Successes+results.Candidate.Successes)
+}
@@ -0,0 +1,90 @@
+package http
+
+import "time"
+
+//defaultFailureRateWindow is the window of FailureRate if FailureRateWindow is not set.
+const defaultFailureRateWindow = time.Minute
+
+//failureRateBuckets is the number of buckets a failure rate window is divided into. The
+//oldest bucket is dropped at once, so the window slides in steps of a tenth.
+const failureRateBuckets = 10
+
+type failureBucket struct {
+	id        int64
+	successes int64
+	failures  int64
+}
+
+//failureWindow counts the successful and failed attempts of the latest window.
+type failureWindow struct {
+	buckets [failureRateBuckets]failureBucket
+}
+
+func (w *failureWindow) add(bucketID int64, failed bool) {
+	bucket := &w.buckets[bucketID%failureRateBuckets]
+	if bucket.id != bucketID {
+		*bucket = failureBucket{id: bucketID}
+	}
+	if failed {
+		bucket.failures++
+	} else {
+		bucket.successes++
+	}
+}
+
+func (w *failureWindow) counts(bucketID int64) (successes, failures int64) {
+	for _, bucket := range w.buckets {
+		if bucket.id > bucketID-failureRateBuckets && bucket.id <= bucketID {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
+
+func (w *failureWindow) rate(bucketID int64) float64 {
+	successes, failures := w.counts(bucketID)
+	if successes+failures == 0 {
+		return 0
+	}
+	return float64(failures) / float64(successes+failures)
+}
+
+//bucketID returns the bucket of the failure rate windows the time falls into.
+func (s *clientStats) bucketID(now time.Time) int64 {
+	return now.UnixNano() / int64(s.failureRateWindow/failureRateBuckets)
+}
+
+//FailureRate returns the share (0 to 1) of the attempts to the host (host[:port] as in the
+//URL of the requests) that failed within the FailureRateWindow. An attempt failed if it
+//got no response or a retryable status. 0 if there was no attempt within the window.
+func (c *FailAwareHTTPClient) FailureRate(host string) float64 {
+	s := c.stats
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hostStats, ok := s.hosts[host]
+	if !ok {
+		return 0
+	}
+	return hostStats.failures.rate(s.bucketID(c.options().Clock.Now()))
+}
+
+//failures returns the number of successful and failed attempts to the host within the window.
+func (s *clientStats) failures(host string, now time.Time) (successes, failures int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hostStats, ok := s.hosts[host]
+	if !ok {
+		return 0, 0
+	}
+	return hostStats.failures.counts(s.bucketID(now))
+}
+
+//resetFailures forgets the attempts to the host, its failure rate starts anew.
+func (s *clientStats) resetFailures(host string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if hostStats, ok := s.hosts[host]; ok {
+		hostStats.failures = failureWindow{}
+	}
+}
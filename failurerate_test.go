@@ -0,0 +1,59 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureWindow(t *testing.T) {
+	window := &failureWindow{}
+	window.add(100, true)
+	window.add(105, false)
+	window.add(109, false)
+	window.add(109, true)
+	assert.Equal(t, 0.5, window.rate(109))
+	assert.Equal(t, 1.0/3.0, window.rate(110), "the oldest bucket left the window")
+	window.add(115, false)
+	assert.Equal(t, 1.0/3.0, window.rate(115), "bucket 105 was replaced")
+	assert.Equal(t, 0.0, window.rate(200))
+}
+
+func TestFailureRate(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	host := fmt.Sprintf("localhost:%d", port)
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.FailureRateWindow = 10 * time.Second
+	//an attempt timing out on a slow machine (e.g. with -race) would send the request again
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	assert.Equal(t, 0.0, client.FailureRate(host))
+	_, err = client.Get("http://" + host + "/ok")
+	assert.Nil(t, err)
+	_, err = client.Get("http://" + host + "/down")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.75, client.FailureRate(host))
+	assert.Equal(t, 0.75, client.Stats().FailureRates[host])
+
+	_, err = client.Get(nonExistingURL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1.0, client.FailureRate("localhost"))
+
+	clock.After(time.Minute)
+	assert.Equal(t, 0.0, client.FailureRate(host), "failures outside of the window are forgotten")
+}
@@ -0,0 +1,87 @@
+package fake
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+)
+
+//HTTPClient is the subset of the methods of failawarehttp.FailAwareHTTPClient that Client
+//mocks. Depend on it instead of the concrete client to swap in the mock in tests.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+	Get(url string) (*http.Response, error)
+	Post(url, contentType string, body io.Reader) (*http.Response, error)
+}
+
+var _ HTTPClient = (*failawarehttp.FailAwareHTTPClient)(nil)
+var _ HTTPClient = (*Client)(nil)
+
+//Result is the scripted result of a call to the Client.
+type Result struct {
+	Response *http.Response
+	Err      error
+}
+
+//Exhausted returns a result that fails like a client giving up after the retries.
+func Exhausted(retries int, lastError error) Result {
+	return Result{Err: failawarehttp.FailAwareHTTPError{
+		Kind:      failawarehttp.KindRetriesExhausted,
+		Retries:   retries,
+		LastError: lastError,
+	}}
+}
+
+//Client is a mock of the fail-aware client. It records every call and returns the
+//scripted results in order. Calls beyond the script fail with ErrScriptExhausted.
+type Client struct {
+	mutex   sync.Mutex
+	results []Result
+	calls   []*http.Request
+}
+
+//NewClient creates a mock that returns the results in order.
+func NewClient(results ...Result) *Client {
+	return &Client{results: results}
+}
+
+//Do records the request and returns the next scripted result.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = append(c.calls, req)
+	if len(c.results) == 0 {
+		return nil, ErrScriptExhausted
+	}
+	result := c.results[0]
+	c.results = c.results[1:]
+	return result.Response, result.Err
+}
+
+//Get records a GET request and returns the next scripted result.
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+//Post records a POST request and returns the next scripted result.
+func (c *Client) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+//Calls returns the requests of all calls made.
+func (c *Client) Calls() []*http.Request {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*http.Request(nil), c.calls...)
+}
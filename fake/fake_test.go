@@ -0,0 +1,63 @@
+package fake
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRetriesScriptedTransport(t *testing.T) {
+	transport := NewTransport(Error(errors.New("connection reset")), Status(503), Step{StatusCode: 200, Body: "ok"})
+	clock := NewClock(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	client := failawarehttp.NewClient(failawarehttp.FailAwareHTTPOptions{
+		Transport:        transport,
+		Clock:            clock,
+		AllowUnsafeRetry: true,
+	})
+
+	rsp, err := client.Post("http://example.com/", "text/plain", strings.NewReader("body"))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(body))
+
+	assert.Equal(t, 3, transport.Attempts())
+	assert.Equal(t, 0, transport.Remaining())
+	assert.Equal(t, 2, len(clock.Waits()))
+}
+
+func TestScriptExhausted(t *testing.T) {
+	transport := NewTransport()
+	rsp, err := transport.RoundTrip(&http.Request{})
+	assert.Nil(t, rsp)
+	assert.Equal(t, ErrScriptExhausted, err)
+}
+
+func TestClientMockRecordsCalls(t *testing.T) {
+	lastErr := errors.New("connection refused")
+	client := NewClient(Result{Response: &http.Response{StatusCode: 204}}, Exhausted(3, lastErr))
+
+	rsp, err := client.Get("http://example.com/a")
+	assert.Nil(t, err)
+	assert.Equal(t, 204, rsp.StatusCode)
+
+	_, err = client.Post("http://example.com/b", "application/json", strings.NewReader("{}"))
+	failErr := err.(failawarehttp.FailAwareHTTPError)
+	assert.Equal(t, 3, failErr.Retries)
+	assert.Equal(t, lastErr, failErr.LastError)
+
+	_, err = client.Get("http://example.com/c")
+	assert.Equal(t, ErrScriptExhausted, err)
+
+	calls := client.Calls()
+	assert.Equal(t, 3, len(calls))
+	assert.Equal(t, "POST", calls[1].Method)
+	assert.Equal(t, "/b", calls[1].URL.Path)
+}
@@ -0,0 +1,144 @@
+//Package fake provides test doubles for code that uses the fail-aware HTTP client:
+//a scriptable Transport, a Clock that does not wait and a Client mock that records calls.
+package fake
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//ErrScriptExhausted is returned by the Transport if more attempts are made than steps were scripted.
+var ErrScriptExhausted = errors.New("fake: no more scripted steps")
+
+//Step is the scripted outcome of a single attempt. If Err is set, the attempt fails with
+//the transport error, otherwise a response with StatusCode, Header and Body is returned.
+type Step struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	Err        error
+}
+
+//Status returns a step that answers with the status code.
+func Status(statusCode int) Step {
+	return Step{StatusCode: statusCode}
+}
+
+//Error returns a step that fails with the transport error.
+func Error(err error) Step {
+	return Step{Err: err}
+}
+
+//Transport is an http.RoundTripper that plays the scripted steps in order, one per
+//attempt. Use it as FailAwareHTTPOptions.Transport.
+type Transport struct {
+	mutex    sync.Mutex
+	steps    []Step
+	requests []*http.Request
+}
+
+//NewTransport creates a Transport with the scripted steps.
+func NewTransport(steps ...Step) *Transport {
+	return &Transport{steps: steps}
+}
+
+//RoundTrip plays the next step of the script.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.requests = append(t.requests, req)
+	if len(t.steps) == 0 {
+		return nil, ErrScriptExhausted
+	}
+	step := t.steps[0]
+	t.steps = t.steps[1:]
+
+	if req.Body != nil {
+		//consume the body like a real transport would
+		ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	if step.Err != nil {
+		return nil, step.Err
+	}
+
+	header := step.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        http.StatusText(step.StatusCode),
+		StatusCode:    step.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(step.Body)),
+		ContentLength: int64(len(step.Body)),
+		Request:       req,
+	}, nil
+}
+
+//Attempts returns the number of attempts made.
+func (t *Transport) Attempts() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.requests)
+}
+
+//Requests returns the requests of all attempts made.
+func (t *Transport) Requests() []*http.Request {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]*http.Request(nil), t.requests...)
+}
+
+//Remaining returns the number of steps not played yet.
+func (t *Transport) Remaining() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.steps)
+}
+
+//Clock is a failawarehttp Clock that never waits. Every wait advances its time
+//instantly and is recorded.
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+//NewClock creates a Clock starting at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+//Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+//After advances the clock by d and returns a channel that is already fired.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+//Waits returns all waits requested so far.
+func (c *Clock) Waits() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
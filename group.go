@@ -0,0 +1,236 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type retryBudget struct {
+	size      int64
+	remaining int64
+}
+
+//takeRetryBudget reports whether the request may be retried. Requests without a
+//shared budget in their context may always be retried. The last retries of the budget
+//are left for the requests with a higher priority.
+func takeRetryBudget(ctx context.Context) bool {
+	budget, ok := ctx.Value(retryBudgetKey).(*retryBudget)
+	if !ok {
+		return true
+	}
+	reserved := budget.size - PriorityFromContext(ctx).share(budget.size)
+	for {
+		remaining := atomic.LoadInt64(&budget.remaining)
+		if remaining <= reserved {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&budget.remaining, remaining, remaining-1) {
+			return true
+		}
+	}
+}
+
+//groupRate spaces the attempts of the requests of a group by the interval.
+type groupRate struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+//reserve reserves the next slot for an attempt and returns how long to wait for it.
+func (r *groupRate) reserve(now time.Time) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	return wait
+}
+
+//waitGroupRate waits for the slot of the next attempt if the request belongs to a group
+//with a MaxRate.
+func (c *FailAwareHTTPClient) waitGroupRate(ctx context.Context, timer *retryTimer) error {
+	rate, ok := ctx.Value(groupRateKey).(*groupRate)
+	if !ok {
+		return nil
+	}
+	wait := rate.reserve(c.options().Clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+	c.options().Logger.Debugf("FAH[Debug]: waiting %dms for the rate limit of the group", wait/time.Millisecond)
+	return timer.wait(ctx, wait)
+}
+
+//GroupOptions are the options for a RequestGroup. The zero value means: no shared
+//retry budget, no concurrency or rate limit and no cancellation on errors.
+type GroupOptions struct {
+	//MaxRetries is the number of retries shared by all requests of the group. Requests with
+	//a lower priority get only a share of them, see Priority.
+	MaxRetries int
+	//MaxConcurrent limits the number of requests of the group in flight.
+	MaxConcurrent int
+	//MaxRate limits the attempts per second of all requests of the group, retries included.
+	//The attempts are spaced evenly instead of being sent in bursts.
+	MaxRate float64
+	//CancelOnError cancels the whole group after the first failed request.
+	CancelOnError bool
+}
+
+//RequestGroup runs requests concurrently with a client and collects their errors,
+//similar to an errgroup. Create one with FailAwareHTTPClient.NewGroup.
+type RequestGroup struct {
+	client  *FailAwareHTTPClient
+	options GroupOptions
+	ctx     context.Context
+	cancel  context.CancelFunc
+	sem     chan struct{}
+	wg      sync.WaitGroup
+
+	mutex  sync.Mutex
+	errors []error
+}
+
+//GroupError is returned by RequestGroup.Wait if at least one request of the group failed.
+type GroupError struct {
+	Errors []error
+}
+
+func (e GroupError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d request(s) failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+//FailAwareErrors returns the FailAwareHTTPErrors of the failed requests, leaving
+//out errors that were returned by the response handlers.
+func (e GroupError) FailAwareErrors() []FailAwareHTTPError {
+	var result []FailAwareHTTPError
+	for _, err := range e.Errors {
+		if failErr, ok := err.(FailAwareHTTPError); ok {
+			result = append(result, failErr)
+		}
+	}
+	return result
+}
+
+//NewGroup creates a RequestGroup bound to this client. All requests of the group
+//are canceled if ctx is done or the group is canceled.
+func (c *FailAwareHTTPClient) NewGroup(ctx context.Context, options GroupOptions) *RequestGroup {
+	groupCtx, cancel := context.WithCancel(ctx)
+	if options.MaxRetries > 0 {
+		groupCtx = context.WithValue(groupCtx, retryBudgetKey, &retryBudget{size: int64(options.MaxRetries), remaining: int64(options.MaxRetries)})
+	}
+	if options.MaxRate > 0 {
+		groupCtx = context.WithValue(groupCtx, groupRateKey, &groupRate{interval: time.Duration(float64(time.Second) / options.MaxRate)})
+	}
+	var sem chan struct{}
+	if options.MaxConcurrent > 0 {
+		sem = make(chan struct{}, options.MaxConcurrent)
+	}
+	return &RequestGroup{
+		client:  c,
+		options: options,
+		ctx:     groupCtx,
+		cancel:  cancel,
+		sem:     sem,
+	}
+}
+
+//Context returns the context shared by all requests of the group.
+func (g *RequestGroup) Context() context.Context {
+	return g.ctx
+}
+
+//Do sends the request in the background, it is canceled if its own context or the context
+//of the group is done. The handler, if not nil, is called with a successful response.
+//The response body is closed after the handler returns.
+func (g *RequestGroup) Do(req *http.Request, handler func(*http.Response) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			select {
+			case g.sem <- struct{}{}:
+				defer func() { <-g.sem }()
+			case <-g.ctx.Done():
+				g.fail(g.ctx.Err())
+				return
+			}
+		}
+
+		ctx, cancel := g.requestContext(req.Context())
+		defer cancel()
+		rsp, err := g.client.Do(req.WithContext(ctx))
+		if err != nil {
+			if rsp != nil {
+				rsp.Body.Close()
+			}
+			g.fail(err)
+			return
+		}
+		defer rsp.Body.Close()
+		if handler != nil {
+			if err := handler(rsp); err != nil {
+				g.fail(err)
+			}
+		}
+	}()
+}
+
+//requestContext returns the context of a request of the group. It keeps the deadline and the
+//values of the context of the request (e.g. its request ID and priority) and is canceled
+//together with the group as well.
+func (g *RequestGroup) requestContext(reqCtx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(reqCtx)
+	if budget, ok := g.ctx.Value(retryBudgetKey).(*retryBudget); ok {
+		ctx = context.WithValue(ctx, retryBudgetKey, budget)
+	}
+	if rate, ok := g.ctx.Value(groupRateKey).(*groupRate); ok {
+		ctx = context.WithValue(ctx, groupRateKey, rate)
+	}
+	go func() {
+		select {
+		case <-g.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (g *RequestGroup) fail(err error) {
+	g.mutex.Lock()
+	g.errors = append(g.errors, err)
+	g.mutex.Unlock()
+	if g.options.CancelOnError {
+		g.cancel()
+	}
+}
+
+//Cancel cancels all requests of the group.
+func (g *RequestGroup) Cancel() {
+	g.cancel()
+}
+
+//Wait blocks until all requests of the group are done. It returns a GroupError if
+//any of them failed.
+func (g *RequestGroup) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if len(g.errors) == 0 {
+		return nil
+	}
+	return GroupError{Errors: g.errors}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupCollectsResponses(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	client := NewDefaultClient()
+	group := client.NewGroup(context.Background(), GroupOptions{MaxConcurrent: 2})
+	bodies := make(chan string, 5)
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest("GET", url, nil)
+		assert.Nil(t, err)
+		group.Do(req, func(rsp *http.Response) error {
+			body, err := ioutil.ReadAll(rsp.Body)
+			bodies <- string(body)
+			return err
+		})
+	}
+
+	assert.Nil(t, group.Wait())
+	close(bodies)
+	for body := range bodies {
+		assert.Equal(t, "200 status code", body)
+	}
+}
+
+func TestGroupSharesRetryBudget(t *testing.T) {
+	client := NewClient(optionsWithMinTimeouts())
+	group := client.NewGroup(context.Background(), GroupOptions{MaxRetries: 1})
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", nonExistingURL, nil)
+		assert.Nil(t, err)
+		group.Do(req, nil)
+	}
+
+	err := group.Wait()
+	assert.NotNil(t, err)
+
+	failErrs := err.(GroupError).FailAwareErrors()
+	assert.Equal(t, 2, len(failErrs))
+	assert.Equal(t, 1, failErrs[0].Retries+failErrs[1].Retries)
+	for _, failErr := range failErrs {
+		assert.Equal(t, KindRetryBudgetExhausted, failErr.Kind)
+	}
+}
+
+func TestGroupCancelOnError(t *testing.T) {
+	client := NewClient(optionsWithMinTimeouts())
+	group := client.NewGroup(context.Background(), GroupOptions{CancelOnError: true})
+	req, err := http.NewRequest("GET", nonExistingURL, nil)
+	assert.Nil(t, err)
+	group.Do(req, nil)
+
+	assert.NotNil(t, group.Wait())
+	assert.NotNil(t, group.Context().Err())
+}
+
+func TestGroupKeepsRequestContext(t *testing.T) {
+	ids := make(chan string, 1)
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get("X-Request-Id")
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.RequestIDHeader = "X-Request-Id"
+	client := NewClient(opts)
+
+	group := client.NewGroup(context.Background(), GroupOptions{})
+	group.Do(mustRequestWithContext(t, WithRequestID(context.Background(), "id-1"), url), nil)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	group.Do(mustRequestWithContext(t, canceled, url), nil)
+
+	err = group.Wait()
+	assert.Equal(t, "id-1", <-ids)
+	if assert.IsType(t, GroupError{}, err) {
+		failErrs := err.(GroupError).FailAwareErrors()
+		assert.Equal(t, 1, len(failErrs), "the canceled request failed")
+		assert.Equal(t, KindCanceled, failErrs[0].Kind)
+	}
+}
+
+func TestGroupRateReserve(t *testing.T) {
+	rate := &groupRate{interval: 100 * time.Millisecond}
+	now := fakeClockStart
+	assert.Equal(t, time.Duration(0), rate.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, rate.reserve(now))
+	assert.Equal(t, 150*time.Millisecond, rate.reserve(now.Add(50*time.Millisecond)))
+	assert.Equal(t, time.Duration(0), rate.reserve(now.Add(time.Second)), "no burst of saved slots")
+}
+
+func TestGroupMaxRate(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	client := NewClient(optionsWithMinTimeouts())
+
+	started := time.Now()
+	group := client.NewGroup(context.Background(), GroupOptions{MaxRate: 50})
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", url, nil)
+		assert.Nil(t, err)
+		group.Do(req, nil)
+	}
+	assert.Nil(t, group.Wait())
+	assert.True(t, time.Since(started) >= 40*time.Millisecond, "3 attempts at 50 per second")
+}
@@ -0,0 +1,14 @@
+//go:build go1.24
+// +build go1.24
+
+package http
+
+import "net/http"
+
+//enableH2C restricts the transport to HTTP/2, cleartext for http:// URLs.
+func enableH2C(transport *http.Transport, logger Logger) {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	transport.Protocols = protocols
+}
@@ -0,0 +1,11 @@
+//go:build !go1.24
+// +build !go1.24
+
+package http
+
+import "net/http"
+
+//enableH2C is not supported, net/http supports HTTP/2 cleartext since Go 1.24 only.
+func enableH2C(transport *http.Transport, logger Logger) {
+	logger.Debugf("FAH[Debug]: H2C requires Go 1.24, requests are sent with HTTP/1.1")
+}
@@ -0,0 +1,36 @@
+//go:build go1.24
+// +build go1.24
+
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestH2C(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{Protocols: protocols, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})}
+	go server.Serve(l)
+	defer server.Close()
+
+	opts := optionsWithMinTimeouts()
+	opts.H2C = true
+	rsp, err := NewClient(opts).Get("http://" + l.Addr().String())
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/2.0", string(body))
+}
@@ -0,0 +1,23 @@
+module github.com/Ragnaroek/failawarehttp/http3
+
+//Develop against the local root module with a workspace: go work init . ./compress ./http3
+
+go 1.24
+
+require (
+	github.com/Ragnaroek/failawarehttp v0.0.0-20261016172651-e9b12768d384
+	github.com/quic-go/quic-go v0.59.1
+	github.com/stretchr/testify v1.11.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	github.com/sirupsen/logrus v1.6.0 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
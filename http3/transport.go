@@ -0,0 +1,140 @@
+//Package http3 provides a transport that sends requests with HTTP/3 (QUIC) and falls back to
+//HTTP/2 or HTTP/1.1 for hosts whose QUIC handshake failed. Use it as the Transport of a
+//FailAwareHTTP client: the attempt with the failed handshake counts as a retry, the retry is
+//sent with the fallback.
+//
+//The package is a module of its own, so the QUIC dependencies are only pulled in by its users.
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+//Options are the options for a Transport, the zero value means the defaults.
+type Options struct {
+	//TLSConfig of the QUIC connections.
+	TLSConfig *tls.Config
+	//HandshakeTimeout limits the QUIC handshake, 5 seconds if not set.
+	HandshakeTimeout time.Duration
+	//Fallback sends the requests to hosts whose QUIC handshake failed and all requests to
+	//http:// URLs, http.DefaultTransport if not set.
+	Fallback http.RoundTripper
+	//FallbackDuration is how long the requests to a host are sent with the Fallback after its
+	//QUIC handshake failed, 5 minutes if not set.
+	FallbackDuration time.Duration
+}
+
+//HandshakeError is returned if the QUIC handshake with a host failed. The requests to the
+//host are sent with the Fallback for the FallbackDuration.
+type HandshakeError struct {
+	Host string
+	Err  error
+}
+
+func (e HandshakeError) Error() string {
+	return fmt.Sprintf("http3: QUIC handshake with %s failed: %s", e.Host, e.Err)
+}
+
+func (e HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+//Transport sends requests with HTTP/3 and falls back to HTTP/2 or HTTP/1.1, see Options.
+type Transport struct {
+	h3               *http3.Transport
+	fallback         http.RoundTripper
+	fallbackDuration time.Duration
+	now              func() time.Time
+
+	mutex         sync.Mutex
+	fallbackUntil map[string]time.Time
+}
+
+//NewTransport creates a new Transport with the options.
+func NewTransport(options Options) *Transport {
+	handshakeTimeout := options.HandshakeTimeout
+	if handshakeTimeout == 0 {
+		handshakeTimeout = 5 * time.Second
+	}
+	fallback := options.Fallback
+	if fallback == nil {
+		fallback = http.DefaultTransport
+	}
+	fallbackDuration := options.FallbackDuration
+	if fallbackDuration == 0 {
+		fallbackDuration = 5 * time.Minute
+	}
+	return &Transport{
+		h3: &http3.Transport{
+			TLSClientConfig: options.TLSConfig,
+			QUICConfig:      &quic.Config{HandshakeIdleTimeout: handshakeTimeout},
+			Dial:            dialHandshake,
+		},
+		fallback:         fallback,
+		fallbackDuration: fallbackDuration,
+		now:              time.Now,
+		fallbackUntil:    make(map[string]time.Time),
+	}
+}
+
+//dialHandshake dials a QUIC connection and waits for the completion of the handshake, so
+//all handshake failures are dial errors.
+func dialHandshake(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error) {
+	conn, err := quic.DialAddr(ctx, addr, tlsConf, conf)
+	if err != nil {
+		return nil, HandshakeError{Host: addr, Err: err}
+	}
+	return conn, nil
+}
+
+//RoundTrip sends the request with HTTP/3, or with the Fallback if the QUIC handshake with
+//the host failed within the FallbackDuration.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || t.usesFallback(req.URL.Host) {
+		return t.fallback.RoundTrip(req)
+	}
+	rsp, err := t.h3.RoundTrip(req)
+	var handshakeErr HandshakeError
+	if errors.As(err, &handshakeErr) {
+		t.mutex.Lock()
+		t.fallbackUntil[req.URL.Host] = t.now().Add(t.fallbackDuration)
+		t.mutex.Unlock()
+	}
+	return rsp, err
+}
+
+func (t *Transport) usesFallback(host string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	until, ok := t.fallbackUntil[host]
+	if !ok {
+		return false
+	}
+	if !t.now().Before(until) {
+		delete(t.fallbackUntil, host)
+		return false
+	}
+	return true
+}
+
+//CloseIdleConnections closes the idle connections of HTTP/3 and of the Fallback.
+func (t *Transport) CloseIdleConnections() {
+	t.h3.CloseIdleConnections()
+	if closer, ok := t.fallback.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+//Close closes the QUIC connections.
+func (t *Transport) Close() error {
+	return t.h3.Close()
+}
@@ -0,0 +1,81 @@
+package http3
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fah "github.com/Ragnaroek/failawarehttp"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+)
+
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, r.Proto)
+})
+
+func clientOptions(transport http.RoundTripper) fah.FailAwareHTTPOptions {
+	return fah.FailAwareHTTPOptions{
+		MaxRetries:         3,
+		Timeout:            2 * time.Second,
+		BackOffDelayFactor: time.Millisecond,
+		Transport:          transport,
+	}
+}
+
+func get(t *testing.T, client *fah.FailAwareHTTPClient, url string) string {
+	rsp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	return string(body)
+}
+
+func TestHTTP3(t *testing.T) {
+	tcpServer := httptest.NewTLSServer(protoHandler)
+	defer tcpServer.Close()
+	udp, err := net.ListenPacket("udp", tcpServer.Listener.Addr().String())
+	if err != nil {
+		t.Skip("UDP port of the server is taken", err)
+	}
+	h3Server := &http3.Server{Handler: protoHandler, TLSConfig: http3.ConfigureTLSConfig(tcpServer.TLS)}
+	go h3Server.Serve(udp)
+	defer h3Server.Close()
+
+	fallback := tcpServer.Client().Transport
+	transport := NewTransport(Options{TLSConfig: fallback.(*http.Transport).TLSClientConfig, Fallback: fallback})
+	defer transport.Close()
+	client := fah.NewClient(clientOptions(transport))
+
+	assert.Equal(t, "HTTP/3.0", get(t, client, tcpServer.URL))
+	assert.Equal(t, int64(0), client.Stats().Retries)
+}
+
+func TestFallbackAfterFailedHandshake(t *testing.T) {
+	tcpServer := httptest.NewTLSServer(protoHandler)
+	defer tcpServer.Close()
+
+	fallback := tcpServer.Client().Transport
+	transport := NewTransport(Options{
+		TLSConfig:        fallback.(*http.Transport).TLSClientConfig,
+		Fallback:         fallback,
+		HandshakeTimeout: 100 * time.Millisecond,
+	})
+	defer transport.Close()
+	client := fah.NewClient(clientOptions(transport))
+
+	assert.Equal(t, "HTTP/1.1", get(t, client, tcpServer.URL))
+	assert.Equal(t, int64(1), client.Stats().Retries, "the failed handshake counts as a retry")
+	assert.Equal(t, "HTTP/1.1", get(t, client, tcpServer.URL))
+	assert.Equal(t, int64(1), client.Stats().Retries, "the host uses the fallback")
+
+	transport.now = func() time.Time { return time.Now().Add(time.Hour) }
+	assert.False(t, transport.usesFallback(tcpServer.Listener.Addr().String()), "HTTP/3 is tried again after the fallback duration")
+}
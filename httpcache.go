@@ -0,0 +1,265 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//fromCacheHeader marks responses served by the HTTP cache, see IsCached.
+const fromCacheHeader = "X-From-Cache"
+
+//cacheableStatus are the status codes that may be cached (RFC 7231 6.1).
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+//IsCached reports whether the response was served by the HTTP cache without a request
+//to the server.
+func IsCached(rsp *http.Response) bool {
+	return rsp.Header.Get(fromCacheHeader) == "1"
+}
+
+//parseCacheControl parses a Cache-Control header into its directives.
+//Directives without an argument have an empty value.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value := part, ""
+			if i := strings.Index(part, "="); i >= 0 {
+				name, value = part[:i], strings.Trim(part[i+1:], `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+	return directives
+}
+
+//freshnessLifetime returns how long a response is fresh according to max-age or Expires
+//(RFC 7234 4.2.1). A private cache ignores s-maxage.
+func freshnessLifetime(header http.Header, directives map[string]string) (time.Duration, bool) {
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	expires := header.Get("Expires")
+	if expires == "" {
+		return 0, false
+	}
+	expiresTime, err := http.ParseTime(expires)
+	if err != nil {
+		//invalid dates, like "0", mean already expired
+		return 0, true
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	return expiresTime.Sub(date), true
+}
+
+//varyValues returns the values of the request headers the response varies on.
+//It returns false if the response varies on everything (Vary: *).
+func varyValues(req *http.Request, header http.Header) (map[string]string, bool) {
+	values := make(map[string]string)
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				values[name] = strings.Join(req.Header.Values(name), ", ")
+			}
+		}
+	}
+	return values, true
+}
+
+func (e *cacheEntry) matchesVary(req *http.Request) bool {
+	for name, value := range e.vary {
+		if strings.Join(req.Header.Values(name), ", ") != value {
+			return false
+		}
+	}
+	return true
+}
+
+//fresh reports whether the entry can be served without contacting the server.
+func (e *cacheEntry) fresh(now time.Time) bool {
+	if e.mustRevalidate {
+		return false
+	}
+	return e.initialAge+now.Sub(e.stored) < e.lifetime
+}
+
+func cacheableMethod(method string) bool {
+	return method == "GET" || method == "HEAD"
+}
+
+//safeMethods do not change the resource, the successful responses of the other methods
+//invalidate it (RFC 7234 4.4).
+var safeMethods = map[string]bool{"GET": true, "HEAD": true, "OPTIONS": true, "TRACE": true}
+
+//lookupHTTPCache returns the cached entry for the request and whether it can be served
+//without contacting the server.
+func (c *FailAwareHTTPClient) lookupHTTPCache(req *http.Request) (*cacheEntry, bool) {
+	if c.httpCache == nil || !cacheableMethod(req.Method) {
+		return nil, false
+	}
+	directives := parseCacheControl(req.Header)
+	if _, ok := directives["no-store"]; ok {
+		return nil, false
+	}
+	entry, ok := c.httpCache.get(cacheKey(req))
+	if !ok || !entry.matchesVary(req) {
+		return nil, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return entry, false
+	}
+	return entry, entry.fresh(c.options().Clock.Now())
+}
+
+//cachedResponse creates a response served from the cache for the request.
+func cachedResponse(req *http.Request, entry *cacheEntry) *http.Response {
+	rsp := entry.response(req)
+	rsp.Header.Set(fromCacheHeader, "1")
+	return rsp
+}
+
+//conditionalRequest adds the validators of a stale entry to the request (RFC 7232), so the
+//server can answer with 304 Not Modified. Requests that are already conditional are not changed.
+func conditionalRequest(req *http.Request, entry *cacheEntry) *http.Request {
+	if entry == nil || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return req
+	}
+	etag := entry.header.Get("ETag")
+	lastModified := entry.header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return req
+	}
+	conditional := req.Clone(req.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+	return conditional
+}
+
+//revalidated refreshes the entry with the headers of a 304 response and serves it from
+//the cache (RFC 7234 4.3.4).
+func (c *FailAwareHTTPClient) revalidated(req *http.Request, entry *cacheEntry, rsp *http.Response, err error) (*http.Response, error) {
+	if err != nil || rsp.StatusCode != http.StatusNotModified {
+		return rsp, err
+	}
+	rsp.Body.Close()
+
+	header := entry.header.Clone()
+	for name, values := range rsp.Header {
+		header[name] = values
+	}
+	c.options().Logger.Debugf("FAH[Debug]: cached response of %s revalidated", req.URL)
+	refreshed, ok := newHTTPCacheEntry(req, entry.statusCode, header, entry.body, c.options().Clock.Now())
+	if !ok {
+		return cachedResponse(req, entry), nil
+	}
+	c.httpCache.put(refreshed)
+	return cachedResponse(req, refreshed), nil
+}
+
+//newHTTPCacheEntry creates an entry for a response if it may be stored (RFC 7234 3).
+//Responses without explicit freshness are only stored if they have a validator.
+func newHTTPCacheEntry(req *http.Request, statusCode int, header http.Header, body []byte, now time.Time) (*cacheEntry, bool) {
+	if !cacheableStatus[statusCode] {
+		return nil, false
+	}
+	if _, ok := parseCacheControl(req.Header)["no-store"]; ok {
+		return nil, false
+	}
+	directives := parseCacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return nil, false
+	}
+	lifetime, ok := freshnessLifetime(header, directives)
+	if !ok && header.Get("ETag") == "" && header.Get("Last-Modified") == "" {
+		return nil, false
+	}
+	vary, ok := varyValues(req, header)
+	if !ok {
+		return nil, false
+	}
+	var initialAge time.Duration
+	if seconds, err := strconv.Atoi(header.Get("Age")); err == nil {
+		initialAge = time.Duration(seconds) * time.Second
+	}
+	_, noCache := directives["no-cache"]
+	return &cacheEntry{
+		key:            cacheKey(req),
+		statusCode:     statusCode,
+		header:         header,
+		body:           body,
+		stored:         now,
+		vary:           vary,
+		lifetime:       lifetime,
+		initialAge:     initialAge,
+		mustRevalidate: noCache,
+	}, true
+}
+
+//invalidateHTTPCache removes the cached responses of the URL of an unsafe request and of the
+//Location and Content-Location of its successful response on the same host (RFC 7234 4.4).
+func (c *FailAwareHTTPClient) invalidateHTTPCache(req *http.Request, rsp *http.Response, err error) {
+	if c.httpCache == nil || err != nil || rsp == nil || safeMethods[req.Method] || rsp.StatusCode < 200 || rsp.StatusCode > 399 {
+		return
+	}
+	urls := []*url.URL{req.URL}
+	for _, name := range []string{"Location", "Content-Location"} {
+		if rsp.Header.Get(name) == "" {
+			continue
+		}
+		if location, err := req.URL.Parse(rsp.Header.Get(name)); err == nil && location.Host == req.URL.Host {
+			urls = append(urls, location)
+		}
+	}
+	for _, invalidated := range urls {
+		for _, method := range []string{"GET", "HEAD"} {
+			c.httpCache.remove(cacheKey(&http.Request{Method: method, URL: invalidated}))
+		}
+	}
+}
+
+//storeInHTTPCache stores a response in the HTTP cache if it is cacheable.
+func (c *FailAwareHTTPClient) storeInHTTPCache(req *http.Request, rsp *http.Response, err error) (*http.Response, error) {
+	if c.httpCache == nil || err != nil || !cacheableMethod(req.Method) || IsCached(rsp) {
+		return rsp, err
+	}
+	//check before reading the body
+	if _, ok := newHTTPCacheEntry(req, rsp.StatusCode, rsp.Header, nil, time.Time{}); !ok {
+		return rsp, err
+	}
+
+	body, readErr := readBody(rsp.Body)
+	rsp.Body.Close()
+	if readErr != nil {
+		return nil, readErr
+	}
+	entry, _ := newHTTPCacheEntry(req, rsp.StatusCode, rsp.Header.Clone(), body, c.options().Clock.Now())
+	c.httpCache.put(entry)
+	stored := entry.response(req)
+	stored.Header = rsp.Header
+	return stored, nil
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cachingServer(t *testing.T, cacheControl string, hits *int32) string {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("Vary", "Accept")
+		w.Write([]byte(fmt.Sprintf("response %d", n)))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	return fmt.Sprintf("http://localhost:%d/resource", port)
+}
+
+func getWithAccept(t *testing.T, client *FailAwareHTTPClient, url, accept string) (*http.Response, string) {
+	req, err := http.NewRequest("GET", url, nil)
+	assert.Nil(t, err)
+	req.Header.Set("Accept", accept)
+	rsp, err := client.Do(req)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	return rsp, string(body)
+}
+
+func TestHTTPCacheServesFreshResponses(t *testing.T) {
+	var hits int32
+	url := cachingServer(t, "max-age=60", &hits)
+	clock := newFakeClock()
+	opts := NewDefaultOptions()
+	opts.HTTPCache = true
+	opts.Clock = clock
+	client := NewClient(opts)
+
+	rsp, body := getWithAccept(t, client, url, "text/plain")
+	assert.False(t, IsCached(rsp))
+	assert.Equal(t, "response 1", body)
+
+	rsp, body = getWithAccept(t, client, url, "text/plain")
+	assert.True(t, IsCached(rsp))
+	assert.Equal(t, "response 1", body)
+
+	rsp, body = getWithAccept(t, client, url, "application/json")
+	assert.False(t, IsCached(rsp))
+	assert.Equal(t, "response 2", body)
+
+	clock.now = clock.now.Add(61 * time.Second)
+	rsp, body = getWithAccept(t, client, url, "application/json")
+	assert.False(t, IsCached(rsp))
+	assert.Equal(t, "response 3", body)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
+
+func TestHTTPCacheHonorsNoStore(t *testing.T) {
+	var hits int32
+	url := cachingServer(t, "no-store", &hits)
+	opts := NewDefaultOptions()
+	opts.HTTPCache = true
+	client := NewClient(opts)
+
+	for i := 0; i < 2; i++ {
+		rsp, _ := getWithAccept(t, client, url, "text/plain")
+		assert.False(t, IsCached(rsp))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestFreshnessLifetimeFromExpires(t *testing.T) {
+	header := http.Header{}
+	header.Set("Date", "Mon, 01 Jun 2020 12:00:00 GMT")
+	header.Set("Expires", "Mon, 01 Jun 2020 12:05:00 GMT")
+	lifetime, ok := freshnessLifetime(header, parseCacheControl(header))
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, lifetime)
+
+	header.Set("Cache-Control", "public, max-age=10")
+	lifetime, ok = freshnessLifetime(header, parseCacheControl(header))
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, lifetime)
+}
+
+func TestHTTPCacheRevalidatesWithETag(t *testing.T) {
+	var hits, notModified int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		if n == 2 {
+			//the revalidation fails once and is retried
+			w.WriteHeader(503)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body v1"))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/resource", port)
+
+	opts := optionsWithMinTimeouts()
+	opts.HTTPCache = true
+	client := NewClient(opts)
+
+	rsp, body := getWithAccept(t, client, url, "text/plain")
+	assert.False(t, IsCached(rsp))
+	assert.Equal(t, "body v1", body)
+
+	rsp, body = getWithAccept(t, client, url, "text/plain")
+	assert.True(t, IsCached(rsp))
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Equal(t, "body v1", body)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+}
+
+func TestConditionalRequestKeepsCallerValidators(t *testing.T) {
+	entry := &cacheEntry{header: http.Header{"Etag": []string{`"v2"`}}}
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	assert.Nil(t, err)
+	conditional := conditionalRequest(req, entry)
+	assert.Equal(t, `"v2"`, conditional.Header.Get("If-None-Match"))
+	assert.Equal(t, "", req.Header.Get("If-None-Match"))
+
+	req.Header.Set("If-None-Match", `"v1"`)
+	assert.True(t, conditionalRequest(req, entry) == req)
+}
+
+func TestHTTPCacheInvalidatedByUnsafeRequests(t *testing.T) {
+	var hits int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		if r.Method != "GET" {
+			w.Header().Set("Location", "/created")
+			w.WriteHeader(201)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(fmt.Sprintf("response %d", n)))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := NewDefaultOptions()
+	opts.HTTPCache = true
+	client := NewClient(opts)
+
+	_, body := getWithAccept(t, client, url+"/resource", "text/plain")
+	assert.Equal(t, "response 1", body)
+	_, body = getWithAccept(t, client, url+"/created", "text/plain")
+	assert.Equal(t, "response 2", body)
+	rsp, _ := getWithAccept(t, client, url+"/resource", "text/plain")
+	assert.True(t, IsCached(rsp))
+
+	req, err := http.NewRequest("PUT", url+"/resource", nil)
+	assert.Nil(t, err)
+	rsp, err = client.Do(req)
+	assert.Nil(t, err)
+	rsp.Body.Close()
+
+	rsp, body = getWithAccept(t, client, url+"/resource", "text/plain")
+	assert.False(t, IsCached(rsp), "invalidated by the PUT")
+	assert.Equal(t, "response 4", body)
+	rsp, body = getWithAccept(t, client, url+"/created", "text/plain")
+	assert.False(t, IsCached(rsp), "invalidated by the Location of the PUT")
+	assert.Equal(t, "response 5", body)
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+//idempotentMethods may be retried without the risk of applying a request twice (RFC 7231 4.2.2).
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"PUT":     true,
+	"DELETE":  true,
+	"OPTIONS": true,
+	"TRACE":   true,
+}
+
+//WithUnsafeRetry returns a context that allows the retry of a request with a non-idempotent
+//method, regardless of FailAwareHTTPOptions.AllowUnsafeRetry.
+func WithUnsafeRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unsafeRetryKey, true)
+}
+
+//retryAllowed reports whether a failed request may be retried. Requests with an
+//Idempotency-Key header are treated as idempotent, like net/http does.
+func retryAllowed(req *http.Request, allowUnsafe bool) bool {
+	if allowUnsafe || idempotentMethods[req.Method] {
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	unsafe, _ := req.Context().Value(unsafeRetryKey).(bool)
+	return unsafe
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"sort"
+	"time"
+)
+
+//latencyWindowSize is the number of the latest attempts per host the percentiles are computed of.
+const latencyWindowSize = 512
+
+//LatencyPercentiles of the latest attempts to a host.
+type LatencyPercentiles struct {
+	P50     time.Duration
+	P95     time.Duration
+	P99     time.Duration
+	Samples int
+}
+
+//latencyWindow keeps the latencies of the latest attempts in a ring buffer.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, latency)
+		return
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+func (w *latencyWindow) percentiles() LatencyPercentiles {
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyPercentiles{
+		P50:     percentile(sorted, 50),
+		P95:     percentile(sorted, 95),
+		P99:     percentile(sorted, 99),
+		Samples: len(sorted),
+	}
+}
+
+//percentile returns the p-th percentile of the sorted latencies with the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+//Latency returns the latency percentiles of the latest attempts to the host (host[:port] as
+//in the URL of the requests). Only attempts that received a response are taken into account.
+func (c *FailAwareHTTPClient) Latency(host string) (LatencyPercentiles, bool) {
+	s := c.stats
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hostStats, ok := s.hosts[host]
+	if !ok || len(hostStats.latencies.samples) == 0 {
+		return LatencyPercentiles{}, false
+	}
+	return hostStats.latencies.percentiles(), true
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	window := &latencyWindow{}
+	for i := 100; i >= 1; i-- {
+		window.add(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, LatencyPercentiles{P50: 50 * time.Millisecond, P95: 95 * time.Millisecond, P99: 99 * time.Millisecond, Samples: 100}, window.percentiles())
+
+	for i := 0; i < latencyWindowSize; i++ {
+		window.add(time.Millisecond)
+	}
+	assert.Equal(t, LatencyPercentiles{P50: time.Millisecond, P95: time.Millisecond, P99: time.Millisecond, Samples: latencyWindowSize}, window.percentiles(), "old samples are dropped")
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+func TestLatencyPerHost(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	host := fmt.Sprintf("localhost:%d", port)
+	client := NewClient(optionsWithMinTimeouts())
+
+	_, ok := client.Latency(host)
+	assert.False(t, ok)
+	for i := 0; i < 3; i++ {
+		_, err = client.Get("http://" + host)
+		assert.Nil(t, err)
+	}
+	_, err = client.Get(nonExistingURL)
+	assert.NotNil(t, err)
+
+	latency, ok := client.Latency(host)
+	assert.True(t, ok)
+	assert.Equal(t, 3, latency.Samples)
+	assert.True(t, latency.P50 >= 5*time.Millisecond, latency.P50)
+	assert.Equal(t, map[string]LatencyPercentiles{host: latency}, client.Stats().Latency, "attempts without response are not taken into account")
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+//ResponseTooLargeError is returned if a response body exceeds the MaxResponseBytes of the
+//client. Reading a body beyond the limit fails with it, a response whose Content-Length
+//exceeds the limit fails the request with KindResponseTooLarge.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+//limitedBody fails reading beyond the limit instead of truncating the body silently.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	//one more byte than allowed tells a body at the limit from one beyond it
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, ResponseTooLargeError{Limit: b.limit}
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+//limitResponse limits the body of the response to the MaxResponseBytes.
+func (c *FailAwareHTTPClient) limitResponse(rsp *http.Response) {
+	if c.options().MaxResponseBytes <= 0 {
+		return
+	}
+	rsp.Body = &limitedBody{ReadCloser: rsp.Body, limit: c.options().MaxResponseBytes, remaining: c.options().MaxResponseBytes}
+}
+
+//responseTooLarge reports whether the response declares a body larger than the MaxResponseBytes.
+func (c *FailAwareHTTPClient) responseTooLarge(rsp *http.Response) bool {
+	return c.options().MaxResponseBytes > 0 && rsp.ContentLength > c.options().MaxResponseBytes
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("x", 100)
+		if r.URL.Path == "/stream" {
+			//no Content-Length
+			w.(http.Flusher).Flush()
+		} else if r.URL.Path == "/small" {
+			body = body[:10]
+		}
+		fmt.Fprint(w, body)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.MaxResponseBytes = 10
+	client := NewClient(opts)
+
+	rsp, err := client.Get(url + "/small")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(body), "a body at the limit")
+
+	_, err = client.Get(url + "/declared")
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindResponseTooLarge, failErr.Kind)
+	assert.Equal(t, ResponseTooLargeError{Limit: 10}, failErr.LastError)
+	assert.Equal(t, 0, failErr.Retries)
+
+	rsp, err = client.Get(url + "/stream")
+	assert.Nil(t, err)
+	body, err = ioutil.ReadAll(rsp.Body)
+	assert.Equal(t, ResponseTooLargeError{Limit: 10}, err)
+	assert.Equal(t, 10, len(body))
+}
@@ -1,5 +1,158 @@
 package http
 
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
 type Logger interface {
 	Debugf(format string, v ...interface{})
 }
+
+//Fields are the structured fields of a log entry.
+type Fields map[string]interface{}
+
+//FieldLogger can be implemented by a Logger to receive the retry logs with structured
+//fields (attempt, wait_ms, method, host, path, status, error_class) instead of a
+//formatted message.
+type FieldLogger interface {
+	Logger
+	DebugFields(msg string, fields Fields)
+}
+
+//logrusLogger adapts logrus to the FieldLogger interface.
+type logrusLogger struct {
+	*logrus.Logger
+}
+
+func (l logrusLogger) DebugFields(msg string, fields Fields) {
+	l.WithFields(logrus.Fields(fields)).Debug(msg)
+}
+
+func (c *FailAwareHTTPClient) logRetry(req *http.Request, attempt int, wait time.Duration, rsp *http.Response, err error) {
+	requestID, _ := RequestIDFromContext(req.Context())
+	fieldLogger, ok := c.options().Logger.(FieldLogger)
+	if !ok {
+		if requestID != "" {
+			c.options().Logger.Debugf("Retry #%d of request %s, waited %dms before retry", attempt, requestID, wait/1000000)
+			return
+		}
+		c.options().Logger.Debugf("Retry #%d of request, waited %dms before retry", attempt, wait/1000000)
+		return
+	}
+
+	fields := Fields{
+		"attempt": attempt,
+		"wait_ms": int64(wait / time.Millisecond),
+		"method":  req.Method,
+		"host":    req.URL.Host,
+		"path":    req.URL.Path,
+	}
+	if rsp != nil {
+		fields["status"] = rsp.StatusCode
+	}
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+	if err != nil {
+		fields["error_class"] = ClassifyError(err).String()
+	}
+	fieldLogger.DebugFields("FAH[Debug]: retry", fields)
+}
+
+//captureBody reads up to limit bytes of the body of the response. The body still returns
+//them, the response may be the one returned to the caller.
+func captureBody(rsp *http.Response, limit int64) []byte {
+	if limit <= 0 {
+		return nil
+	}
+	captured, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, limit))
+	rsp.Body = &capturedBody{Reader: io.MultiReader(bytes.NewReader(captured), rsp.Body), body: rsp.Body}
+	return captured
+}
+
+//capturedBody returns the captured start of a body before the rest of it.
+type capturedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *capturedBody) Close() error {
+	return b.body.Close()
+}
+
+//RequestLogHook is called before every attempt with the attempt number (starting at 0) and
+//the request as it is sent, with all headers the client adds (e.g. the token, the request ID
+//and the DefaultHeaders). Headers of the passed request are redacted, see
+//FailAwareHTTPOptions.RedactHeaders.
+type RequestLogHook func(Logger, *http.Request, int)
+
+//ResponseLogHook is called with every response received, including the ones that
+//are retried. Headers of the passed response are redacted.
+type ResponseLogHook func(Logger, *http.Response)
+
+const redacted = "REDACTED"
+
+//defaultRedactedHeaders are always redacted, before handing requests or responses
+//to the log hooks or the debug log.
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+//RedactHeader returns a copy of the header with the values of the always redacted headers
+//(Authorization, Proxy-Authorization, Cookie and Set-Cookie) and of the additional ones replaced.
+func RedactHeader(header http.Header, additional ...string) http.Header {
+	return redactHeader(header, redactedHeaderNames(additional))
+}
+
+func redactedHeaderNames(additional []string) map[string]bool {
+	names := make(map[string]bool, len(defaultRedactedHeaders)+len(additional))
+	for _, name := range defaultRedactedHeaders {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range additional {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	return names
+}
+
+func redactHeader(header http.Header, names map[string]bool) http.Header {
+	if header == nil {
+		return nil
+	}
+	result := make(http.Header, len(header))
+	for key, values := range header {
+		if names[http.CanonicalHeaderKey(key)] {
+			result[key] = []string{redacted}
+		} else {
+			result[key] = values
+		}
+	}
+	return result
+}
+
+//redactRequest returns a shallow copy of the request with redacted headers.
+//The original request is not modified.
+func redactRequest(req *http.Request, names map[string]bool) *http.Request {
+	if req == nil {
+		return nil
+	}
+	result := *req
+	result.Header = redactHeader(req.Header, names)
+	return &result
+}
+
+//redactResponse returns a shallow copy of the response with redacted headers.
+//The original response is not modified.
+func redactResponse(rsp *http.Response, names map[string]bool) *http.Response {
+	if rsp == nil {
+		return nil
+	}
+	result := *rsp
+	result.Header = redactHeader(rsp.Header, names)
+	result.Request = redactRequest(rsp.Request, names)
+	return &result
+}
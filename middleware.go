@@ -0,0 +1,39 @@
+package http
+
+import "net/http"
+
+//RoundTripFunc sends a single attempt of a request.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+//RoundTrip implements http.RoundTripper.
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//Middleware wraps the sending of every attempt. Use AttemptFromContext to find out
+//which attempt of a request is sent.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+//Use adds middlewares to the client. The first middleware added is the outermost one.
+//Middlewares are applied to every attempt, inside the retry loop.
+func (c *FailAwareHTTPClient) Use(middlewares ...Middleware) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.middlewares = append(c.middlewares, middlewares...)
+	chain := RoundTripFunc(c.httpClient.Do)
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		chain = c.middlewares[i](chain)
+	}
+	c.chain = chain
+}
+
+//send sends a single attempt through the middlewares.
+func (c *FailAwareHTTPClient) send(req *http.Request) (*http.Response, error) {
+	c.mutex.RLock()
+	chain := c.chain
+	c.mutex.RUnlock()
+	if chain == nil {
+		return c.httpClient.Do(req)
+	}
+	return chain(req)
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewaresWrapEveryAttempt(t *testing.T) {
+	var seen [][]string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Values("X-Chain"))
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	header := func(value string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				attempt, ok := AttemptFromContext(req.Context())
+				assert.True(t, ok)
+				req.Header.Add("X-Chain", value+strconv.Itoa(attempt))
+				return next(req)
+			}
+		}
+	}
+
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would add an attempt to the chain
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+	client.Use(header("outer"))
+	client.Use(header("inner"))
+
+	rsp, err := client.Get(url)
+	assert.Nil(t, err)
+	assert.Equal(t, 503, rsp.StatusCode)
+	assert.Equal(t, [][]string{{"outer0", "inner0"}, {"outer1", "inner1"}, {"outer2", "inner2"}}, seen)
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+//UploadMultipart posts the fields and files (form field to file path) as multipart/form-data.
+//The body is streamed and the files are read again for every attempt, they are not buffered.
+//As POST is not idempotent, the upload is only retried with AllowUnsafeRetry or a context
+//from WithUnsafeRetry.
+func (c *FailAwareHTTPClient) UploadMultipart(ctx context.Context, url string, fields map[string]string, files map[string]string) (*http.Response, error) {
+	for _, path := range files {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	boundary := multipart.NewWriter(ioutil.Discard).Boundary()
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, streamedBodyKey, true), "POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	req.GetBody = func() (io.ReadCloser, error) {
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(writeMultipart(writer, boundary, fields, files))
+		}()
+		return reader, nil
+	}
+	return c.Do(req)
+}
+
+//writeMultipart writes the multipart body, fields and files are sorted by their name.
+func writeMultipart(w io.Writer, boundary string, fields map[string]string, files map[string]string) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(fields) {
+		if err := mw.WriteField(name, fields[name]); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(files) {
+		if err := writeFile(mw, name, files[name]); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func writeFile(mw *multipart.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	part, err := mw.CreateFormFile(name, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadMultipart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multipart")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.csv")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("a,b\n1,2\n"), 0600))
+
+	var uploads []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		file, header, err := r.FormFile("report")
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		uploads = append(uploads, fmt.Sprintf("%s %s %s", r.FormValue("owner"), header.Filename, content))
+		if len(uploads) == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(201)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	ctx := WithUnsafeRetry(context.Background())
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would upload the file again
+	opts.Timeout = time.Second
+	rsp, err := NewClient(opts).UploadMultipart(ctx, fmt.Sprintf("http://localhost:%d", port),
+		map[string]string{"owner": "finance"}, map[string]string{"report": path})
+	assert.Nil(t, err)
+	assert.Equal(t, 201, rsp.StatusCode)
+	assert.Equal(t, []string{"finance report.csv a,b\n1,2\n", "finance report.csv a,b\n1,2\n"}, uploads)
+}
+
+func TestUploadMultipartMissingFile(t *testing.T) {
+	_, err := NewClient(optionsWithMinTimeouts()).UploadMultipart(context.Background(), nonExistingURL,
+		nil, map[string]string{"report": "/does/not/exist.csv"})
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,51 @@
+package http
+
+//options returns the current options of the client. The returned options must not be
+//modified, UpdateOptions replaces them as a whole.
+func (c *FailAwareHTTPClient) options() *FailAwareHTTPOptions {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.opts
+}
+
+//UpdateOptions changes the options of the client at runtime, e.g. to raise MaxRetries or to
+//switch the back off strategy from a feature flag watcher. update gets a copy of the current
+//options, unset options are replaced by their defaults as by NewClient. Requests in flight
+//see the new options from their next attempt on.
+//The options the client is built from are not changed though: the Transport and its tuning,
+//the caches, the Endpoints and the Resolver, RedactHeaders, RandSource, FailureRateWindow,
+//ExpvarName and the redirect policy. update must not use the client.
+func (c *FailAwareHTTPClient) UpdateOptions(update func(options *FailAwareHTTPOptions)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	options := *c.opts
+	update(&options)
+	options = withDefaults(options)
+	c.opts = &options
+}
+
+//WithOptions creates a client that shares the transport and so the connection pool with this
+//client, but whose options are changed by update, e.g. a different MaxRetries or Timeout per
+//tenant. update gets a copy of the current options, unset options are replaced by their
+//defaults as by NewClient. The derived client has the middlewares of this client, its own
+//caches, endpoint pool and Stats. The transport options (Transport and its tuning) and
+//RandSource of this client are kept. Without an ExpvarName of its own the Stats of the
+//derived client are not published, the name is taken by this client.
+func (c *FailAwareHTTPClient) WithOptions(update func(options *FailAwareHTTPOptions)) *FailAwareHTTPClient {
+	c.mutex.RLock()
+	options := *c.opts
+	middlewares := append([]Middleware(nil), c.middlewares...)
+	c.mutex.RUnlock()
+	update(&options)
+	derived := newClient(withDefaults(options), c.httpClient.Transport, c.dnsCache, c.random)
+	if len(middlewares) > 0 {
+		derived.Use(middlewares...)
+	}
+	return derived
+}
+
+//Clone creates a client with the options of this client that shares its transport,
+//see WithOptions.
+func (c *FailAwareHTTPClient) Clone() *FailAwareHTTPClient {
+	return c.WithOptions(func(*FailAwareHTTPOptions) {})
+}
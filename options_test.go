@@ -0,0 +1,112 @@
+package http
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateOptions(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 1
+	client := NewClient(opts)
+
+	_, err := client.Get(nonExistingURL)
+	assert.Equal(t, 1, err.(FailAwareHTTPError).Retries)
+
+	client.UpdateOptions(func(options *FailAwareHTTPOptions) {
+		options.MaxRetries = 3
+		options.BackOffStrategy = BackOffConstant
+	})
+	_, err = client.Get(nonExistingURL)
+	assert.Equal(t, 3, err.(FailAwareHTTPError).Retries)
+	assert.Equal(t, BackOffConstant, client.options().BackOffStrategy)
+
+	client.UpdateOptions(func(options *FailAwareHTTPOptions) {
+		options.Timeout = 0
+		options.Logger = nil
+	})
+	assert.Equal(t, defaultOptions.Timeout, client.options().Timeout, "unset options get their default")
+	assert.NotNil(t, client.options().Logger)
+	assert.Equal(t, 3, client.options().MaxRetries)
+}
+
+func TestUpdateOptionsConcurrently(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 2
+	opts.BackOffDelayFactor = time.Millisecond
+	client := NewClient(opts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Get(nonExistingURL)
+		}()
+		go func(i int) {
+			defer wg.Done()
+			client.UpdateOptions(func(options *FailAwareHTTPOptions) {
+				options.MaxRetries = 1 + i%2
+			})
+		}(i)
+	}
+	wg.Wait()
+	assert.True(t, client.options().MaxRetries >= 1)
+}
+
+func TestWithOptions(t *testing.T) {
+	var connections int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = 5 * time.Second
+	opts.MaxRetries = 5
+	client := NewClient(opts)
+	var middlewareCalls int64
+	client.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt64(&middlewareCalls, 1)
+			return next(req)
+		}
+	})
+	derived := client.WithOptions(func(options *FailAwareHTTPOptions) {
+		options.MaxRetries = 1
+		options.DefaultHeaders = http.Header{"X-Tenant": {"a"}}
+	})
+	clone := client.Clone()
+
+	var tenants []string
+	for _, c := range []*FailAwareHTTPClient{client, derived, clone} {
+		rsp, err := c.Get(server.URL)
+		assert.Nil(t, err)
+		ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		tenants = append(tenants, rsp.Header.Get("X-Tenant"))
+	}
+	assert.Equal(t, []string{"", "a", ""}, tenants)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&connections), "the connection is shared")
+	assert.Equal(t, int64(3), atomic.LoadInt64(&middlewareCalls))
+
+	assert.Equal(t, 5, client.options().MaxRetries)
+	assert.Equal(t, 1, derived.options().MaxRetries)
+	assert.Equal(t, 5, clone.options().MaxRetries)
+	assert.Equal(t, int64(1), derived.Stats().Requests)
+	assert.Equal(t, int64(1), client.Stats().Requests)
+}
@@ -0,0 +1,231 @@
+//Package outbox delivers requests durably: they are stored before they are sent with the
+//fail-aware client and delivered again after failures and process restarts, e.g. for
+//telemetry or webhooks.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+)
+
+const defaultInterval = 10 * time.Second
+
+//Doer sends requests, usually a *failawarehttp.FailAwareHTTPClient.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+//Message is a stored request.
+type Message struct {
+	ID       string      `json:"id"`
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	Enqueued time.Time   `json:"enqueued"`
+	//Attempts is the number of failed deliveries.
+	Attempts int `json:"attempts"`
+	//Errors are the errors of the failed deliveries.
+	Errors []string `json:"errors,omitempty"`
+}
+
+//Store persists the messages of an Outbox.
+type Store interface {
+	//Put stores a new or changed message.
+	Put(msg Message) error
+	//Delete removes a delivered message.
+	Delete(id string) error
+	//List returns the stored messages in the order they were enqueued.
+	List() ([]Message, error)
+}
+
+//Options configure an Outbox. The zero value delivers failed messages again every 10s,
+//without limit.
+type Options struct {
+	//Interval is the time between deliveries of messages that failed.
+	Interval time.Duration
+	//MaxAttempts is the number of failed deliveries after which a message is dead.
+	MaxAttempts int
+	//DeadLetter is called with every dead message: failed MaxAttempts times or rejected
+	//by the receiver with a client error. The message is removed from the store after it.
+	DeadLetter func(msg Message)
+	//DeadLetterStore keeps the dead messages, e.g. to deliver them manually later.
+	DeadLetterStore Store
+}
+
+//Outbox stores requests and delivers them in the background, see Run.
+type Outbox struct {
+	client  Doer
+	store   Store
+	options Options
+	wake    chan struct{}
+
+	mutex sync.Mutex
+}
+
+//New creates an Outbox that sends the messages of store with client.
+func New(client Doer, store Store, options Options) *Outbox {
+	if options.Interval <= 0 {
+		options.Interval = defaultInterval
+	}
+	return &Outbox{client: client, store: store, options: options, wake: make(chan struct{}, 1)}
+}
+
+//Enqueue stores the request for delivery and returns the ID of its message. The ID is
+//sent as Idempotency-Key, so the receiver can detect duplicate deliveries.
+func (o *Outbox) Enqueue(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	msg := Message{
+		ID:       id,
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Header:   req.Header.Clone(),
+		Body:     body,
+		Enqueued: time.Now(),
+	}
+	if err := o.store.Put(msg); err != nil {
+		return "", err
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+//Run delivers the stored messages, including the ones left by a previous process,
+//until ctx is done.
+func (o *Outbox) Run(ctx context.Context) error {
+	for {
+		if err := o.Deliver(ctx); err != nil {
+			return err
+		}
+		timer := time.NewTimer(o.options.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-o.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+//Deliver sends all stored messages once. Delivered messages are deleted from the store.
+//It returns an error only if the store fails.
+func (o *Outbox) Deliver(ctx context.Context) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	msgs, err := o.store.List()
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		rejected, err := o.send(ctx, msg)
+		if err == nil {
+			if err := o.store.Delete(msg.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		msg.Attempts++
+		msg.Errors = append(msg.Errors, describe(err))
+		if rejected || (o.options.MaxAttempts > 0 && msg.Attempts >= o.options.MaxAttempts) {
+			if err := o.deadLetter(msg); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := o.store.Put(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//deadLetter hands the message to the DeadLetterStore and DeadLetter and removes it.
+func (o *Outbox) deadLetter(msg Message) error {
+	if o.options.DeadLetterStore != nil {
+		if err := o.options.DeadLetterStore.Put(msg); err != nil {
+			return err
+		}
+	}
+	if o.options.DeadLetter != nil {
+		o.options.DeadLetter(msg)
+	}
+	return o.store.Delete(msg.ID)
+}
+
+//describe returns the error of a delivery for the error history of a message.
+func describe(err error) string {
+	var failErr failawarehttp.FailAwareHTTPError
+	if errors.As(err, &failErr) {
+		return fmt.Sprintf("%s after %d retries: %v", failErr.Kind, failErr.Retries, failErr.LastError)
+	}
+	return err.Error()
+}
+
+//send delivers a message. It reports whether the receiver rejected the message with a
+//client error (4xx but 408, 425 and 429), as delivering it again would not change that.
+func (o *Outbox) send(ctx context.Context, msg Message) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, msg.Method, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return true, err
+	}
+	req.Header = msg.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Idempotency-Key", msg.ID)
+	rsp, err := o.client.Do(req)
+	if err != nil {
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+		return false, err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode >= 500 || rsp.StatusCode == http.StatusRequestTimeout ||
+		rsp.StatusCode == http.StatusTooEarly || rsp.StatusCode == http.StatusTooManyRequests {
+		return false, fmt.Errorf("outbox: delivery failed with status %d", rsp.StatusCode)
+	}
+	if rsp.StatusCode >= 400 {
+		return true, fmt.Errorf("outbox: rejected with status %d", rsp.StatusCode)
+	}
+	return false, nil
+}
+
+//newID returns an ID that sorts in the order of creation.
+func newID() (string, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(random)), nil
+}
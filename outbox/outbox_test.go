@@ -0,0 +1,197 @@
+package outbox
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+	"github.com/stretchr/testify/assert"
+)
+
+type receiver struct {
+	mutex    sync.Mutex
+	status   int
+	bodies   []string
+	keys     []string
+	received chan struct{}
+}
+
+func newReceiver(status int) (*receiver, *httptest.Server) {
+	r := &receiver{status: status, received: make(chan struct{}, 10)}
+	return r, httptest.NewServer(r)
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	body, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(body))
+	r.keys = append(r.keys, req.Header.Get("Idempotency-Key"))
+	w.WriteHeader(r.status)
+	r.received <- struct{}{}
+}
+
+func testClient() *failawarehttp.FailAwareHTTPClient {
+	return failawarehttp.NewClient(failawarehttp.FailAwareHTTPOptions{
+		MaxRetries:         1,
+		Timeout:            time.Second,
+		BackOffDelayFactor: time.Millisecond,
+	})
+}
+
+func tempStore(t *testing.T) (*FileStore, func()) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err)
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestDeliverAfterRestart(t *testing.T) {
+	store, cleanup := tempStore(t)
+	defer cleanup()
+	down, downServer := newReceiver(503)
+	defer downServer.Close()
+
+	outbox := New(testClient(), store, Options{})
+	req, err := http.NewRequest("POST", downServer.URL, strings.NewReader(`{"event":"signup"}`))
+	assert.Nil(t, err)
+	id, err := outbox.Enqueue(req)
+	assert.Nil(t, err)
+	assert.Nil(t, outbox.Deliver(context.Background()))
+
+	msgs, err := store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, 1, msgs[0].Attempts)
+	assert.Equal(t, []string{id}, down.keys)
+
+	//a new process with the same store delivers to the recovered receiver
+	up, upServer := newReceiver(202)
+	defer upServer.Close()
+	msgs[0].URL = upServer.URL
+	assert.Nil(t, store.Put(msgs[0]))
+
+	restarted := New(testClient(), store, Options{})
+	assert.Nil(t, restarted.Deliver(context.Background()))
+	assert.Equal(t, []string{`{"event":"signup"}`}, up.bodies)
+	assert.Equal(t, []string{id}, up.keys)
+	msgs, err = store.List()
+	assert.Nil(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestRun(t *testing.T) {
+	store, cleanup := tempStore(t)
+	defer cleanup()
+	r, server := newReceiver(200)
+	defer server.Close()
+
+	outbox := New(testClient(), store, Options{Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- outbox.Run(ctx)
+	}()
+
+	for _, event := range []string{"a", "b"} {
+		req, err := http.NewRequest("POST", server.URL, strings.NewReader(event))
+		assert.Nil(t, err)
+		_, err = outbox.Enqueue(req)
+		assert.Nil(t, err)
+		<-r.received
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, []string{"a", "b"}, r.bodies)
+}
+
+func TestDeadLetterAfterMaxAttempts(t *testing.T) {
+	store, cleanup := tempStore(t)
+	defer cleanup()
+	deadStore, deadCleanup := tempStore(t)
+	defer deadCleanup()
+	_, server := newReceiver(503)
+	defer server.Close()
+
+	var dead []Message
+	outbox := New(testClient(), store, Options{
+		MaxAttempts:     2,
+		DeadLetterStore: deadStore,
+		DeadLetter: func(msg Message) {
+			dead = append(dead, msg)
+		},
+	})
+	req, err := http.NewRequest("POST", server.URL, strings.NewReader("payload"))
+	assert.Nil(t, err)
+	_, err = outbox.Enqueue(req)
+	assert.Nil(t, err)
+
+	assert.Nil(t, outbox.Deliver(context.Background()))
+	assert.Empty(t, dead)
+	assert.Nil(t, outbox.Deliver(context.Background()))
+	assert.Equal(t, 1, len(dead))
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, []string{"outbox: delivery failed with status 503", "outbox: delivery failed with status 503"}, dead[0].Errors)
+	assert.Equal(t, []byte("payload"), dead[0].Body)
+
+	msgs, err := store.List()
+	assert.Nil(t, err)
+	assert.Empty(t, msgs)
+	deadMsgs, err := deadStore.List()
+	assert.Nil(t, err)
+	assert.Equal(t, dead, deadMsgs)
+}
+
+func TestDeadLetterOnRejection(t *testing.T) {
+	store, cleanup := tempStore(t)
+	defer cleanup()
+	_, server := newReceiver(400)
+	defer server.Close()
+
+	var dead []Message
+	outbox := New(testClient(), store, Options{DeadLetter: func(msg Message) {
+		dead = append(dead, msg)
+	}})
+	req, err := http.NewRequest("POST", server.URL, strings.NewReader("invalid"))
+	assert.Nil(t, err)
+	_, err = outbox.Enqueue(req)
+	assert.Nil(t, err)
+
+	assert.Nil(t, outbox.Deliver(context.Background()))
+	assert.Equal(t, 1, len(dead))
+	assert.Equal(t, []string{"outbox: rejected with status 400"}, dead[0].Errors)
+}
+
+func TestFileStoreSkipsCorruptMessages(t *testing.T) {
+	store, cleanup := tempStore(t)
+	defer cleanup()
+	var corrupt []string
+	store.OnCorrupt = func(path string, err error) {
+		assert.NotNil(t, err)
+		corrupt = append(corrupt, filepath.Base(path))
+	}
+	assert.Nil(t, store.Put(Message{ID: "1", Method: "POST"}))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(store.dir, "2.json"), []byte(`{"id":`), 0600))
+	assert.Nil(t, store.Put(Message{ID: "3", Method: "POST"}))
+
+	msgs, err := store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, []string{"2.json"}, corrupt)
+	_, err = os.Stat(filepath.Join(store.dir, "2.json.corrupt"))
+	assert.Nil(t, err, "moved aside")
+
+	msgs, err = store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, 1, len(corrupt), "reported once")
+}
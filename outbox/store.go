@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//FileStore stores every message as a JSON file in a directory.
+type FileStore struct {
+	//OnCorrupt is called with the path and the error of every message file List cannot
+	//decode. The file is moved aside to <path>.corrupt, so it does not block the delivery
+	//of the other messages.
+	OnCorrupt func(path string, err error)
+
+	dir string
+}
+
+//NewFileStore creates a FileStore in dir, which is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+//Put writes the message to a temporary file that replaces the file of the message,
+//so a crash never leaves a partially written message. The file and the directory are
+//synced, the message is on disk when Put returns.
+func (s *FileStore) Put(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, msg.ID+".tmp")
+	if err := writeSynced(tmp, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path(msg.ID)); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//syncDir makes the renames in the directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+//Delete removes the file of the message.
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+//List reads all messages, ordered by their IDs. Files that cannot be read are skipped, see
+//OnCorrupt.
+func (s *FileStore) List() ([]Message, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".json") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	msgs := make([]Message, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			//deleted since the directory was read
+			continue
+		}
+		if err != nil {
+			//e.g. no permission, tried again by the next List
+			s.corrupt(path, err)
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			if renameErr := os.Rename(path, path+".corrupt"); renameErr != nil {
+				err = fmt.Errorf("%v, not moved aside: %v", err, renameErr)
+			}
+			s.corrupt(path, err)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (s *FileStore) corrupt(path string, err error) {
+	if s.OnCorrupt != nil {
+		s.OnCorrupt(path, err)
+	}
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//hostPace is the latest quota of a host and when its next request may be sent.
+type hostPace struct {
+	quota rateLimitQuota
+	//interval between the requests to use up the remaining quota evenly until the reset
+	interval time.Duration
+	next     time.Time
+}
+
+//rateLimitPacer spreads the requests to a host evenly over the rest of its rate limit
+//window, see FailAwareHTTPOptions.PaceRateLimits.
+type rateLimitPacer struct {
+	mutex sync.Mutex
+	hosts map[string]*hostPace
+}
+
+func newRateLimitPacer(enabled bool) *rateLimitPacer {
+	if !enabled {
+		return nil
+	}
+	return &rateLimitPacer{hosts: map[string]*hostPace{}}
+}
+
+//update learns the quota of the host from the headers of a response.
+func (p *rateLimitPacer) update(host string, header http.Header, now time.Time) {
+	quota, ok := parseRateLimitQuota(header, now)
+	if !ok || !quota.hasRemaining {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pace, ok := p.hosts[host]
+	if !ok {
+		pace = &hostPace{}
+		p.hosts[host] = pace
+	}
+	pace.quota = quota
+	if quota.remaining > 0 {
+		pace.interval = quota.reset.Sub(now) / time.Duration(quota.remaining)
+	}
+}
+
+//reserve returns how long a request to the host has to wait. Without quota left it waits
+//until the reset, else the requests are spaced by the interval.
+func (p *rateLimitPacer) reserve(host string, now time.Time) time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pace, ok := p.hosts[host]
+	if !ok {
+		return 0
+	}
+	if !now.Before(pace.quota.reset) {
+		//the window is over, the quota of the next one is learned from the next response
+		delete(p.hosts, host)
+		return 0
+	}
+	if pace.quota.remaining <= 0 {
+		return pace.quota.reset.Sub(now)
+	}
+	start := now
+	if pace.next.After(start) {
+		start = pace.next
+	}
+	pace.next = start.Add(pace.interval)
+	pace.quota.remaining--
+	return start.Sub(now)
+}
+
+//pace waits until a request to the host may be sent.
+func (c *FailAwareHTTPClient) pace(ctx context.Context, host string, timer *retryTimer) error {
+	wait := c.pacer.reserve(host, c.options().Clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+	c.options().Logger.Debugf("FAH[Debug]: pacing request to %s for %dms to stay within its rate limit", host, wait/time.Millisecond)
+	return timer.wait(ctx, wait)
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitPacerReserve(t *testing.T) {
+	pacer := newRateLimitPacer(true)
+	now := fakeClockStart
+	assert.Equal(t, time.Duration(0), pacer.reserve("api", now), "unknown hosts are not paced")
+
+	pacer.update("api", http.Header{"X-Ratelimit-Remaining": {"4"}, "X-Ratelimit-Reset": {"8"}}, now)
+	var waits []time.Duration
+	for i := 0; i < 5; i++ {
+		waits = append(waits, pacer.reserve("api", now))
+	}
+	assert.Equal(t, []time.Duration{0, 2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second}, waits)
+
+	pacer.update("api", http.Header{"Ratelimit": {"remaining=0, reset=3"}}, now)
+	assert.Equal(t, 3*time.Second, pacer.reserve("api", now))
+	assert.Equal(t, time.Duration(0), pacer.reserve("api", now.Add(3*time.Second)), "a new window starts")
+	assert.Equal(t, time.Duration(0), pacer.reserve("other", now))
+}
+
+func TestPaceRateLimits(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", "5")
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be retried
+	opts.Timeout = time.Second
+	opts.Clock = clock
+	opts.PaceRateLimits = true
+	client := NewClient(opts)
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	rsp, err := client.Get(url)
+	assert.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, 0, len(clock.waits))
+	rsp, err = client.Get(url)
+	assert.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, []time.Duration{5 * time.Second}, clock.waits, "the second request waits for the reset")
+}
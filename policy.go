@@ -0,0 +1,167 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+//RetryPolicy decides how often and how fast a request is retried. Zero values are
+//taken from the options of the client, DisableJitter can only be switched on.
+type RetryPolicy struct {
+	//MaxRetries is the number of attempts. Deprecated: use MaxAttempts.
+	MaxRetries int
+	//MaxAttempts is the number of attempts, RetryForever retries until the context is done.
+	//It takes precedence over MaxRetries.
+	MaxAttempts        int
+	BackOffDelayFactor time.Duration
+	DisableJitter      bool
+	//RetryImmediately sends the first retry without waiting, the back off starts with the
+	//second retry. Like DisableJitter it can only be switched on.
+	RetryImmediately bool
+	//BackOffStrategy selects how the back off grows. BackOffExponential takes the strategy of the client.
+	BackOffStrategy BackOffStrategy
+	//Jitter selects how the back off is randomized. JitterRelative takes the mode of the client.
+	Jitter JitterMode
+	//RetryableStatuses replaces the default retryable status codes (5xx but 501 and 505, 408, 425 and 429) if set.
+	RetryableStatuses []int
+	//Timeout of a single attempt, including reading the response body. A negative
+	//timeout disables it, e.g. for streamed responses.
+	Timeout time.Duration
+	//MaxBackOff caps the wait before a single retry.
+	MaxBackOff time.Duration
+	//RetryableErrors are the classes of retried transport errors, all but ClassCertificate if nil.
+	RetryableErrors []ErrorClass
+}
+
+func (p RetryPolicy) withDefaults(defaults RetryPolicy) RetryPolicy {
+	result := p
+	if result.MaxRetries == 0 {
+		result.MaxRetries = defaults.MaxRetries
+	}
+	if p.MaxAttempts == 0 && p.MaxRetries == 0 {
+		result.MaxAttempts = defaults.MaxAttempts
+	}
+	if result.BackOffDelayFactor == 0 {
+		result.BackOffDelayFactor = defaults.BackOffDelayFactor
+	}
+	result.DisableJitter = p.DisableJitter || defaults.DisableJitter
+	result.RetryImmediately = p.RetryImmediately || defaults.RetryImmediately
+	if result.BackOffStrategy == BackOffExponential {
+		result.BackOffStrategy = defaults.BackOffStrategy
+	}
+	if result.Jitter == JitterRelative {
+		result.Jitter = defaults.Jitter
+	}
+	if result.RetryableStatuses == nil {
+		result.RetryableStatuses = defaults.RetryableStatuses
+	}
+	if result.Timeout == 0 {
+		result.Timeout = defaults.Timeout
+	}
+	if result.RetryableErrors == nil {
+		result.RetryableErrors = defaults.RetryableErrors
+	}
+	if result.MaxBackOff == 0 {
+		result.MaxBackOff = defaults.MaxBackOff
+	}
+	return result
+}
+
+//RetryForever as MaxAttempts retries a request until its context is done.
+const RetryForever = -1
+
+//attempts returns the number of attempts of a request, negative for unlimited attempts.
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts != 0 {
+		return p.MaxAttempts
+	}
+	return p.MaxRetries
+}
+
+//retryable reports whether a response with the status code is retried.
+func (p RetryPolicy) retryable(statusCode int) bool {
+	if p.RetryableStatuses == nil {
+		return retryableStatus(statusCode)
+	}
+	for _, retryable := range p.RetryableStatuses {
+		if statusCode == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+//retryableError reports whether an attempt that failed with the transport error is retried.
+//Certificate errors will not fix themselves and are never retried.
+func (p RetryPolicy) retryableError(err error) bool {
+	if errors.Is(err, ErrChecksumMismatch) {
+		return true
+	}
+	var invalid InvalidResponseError
+	if errors.As(err, &invalid) {
+		return invalid.Retryable
+	}
+	class := ClassifyError(err)
+	if class == ClassCertificate {
+		return false
+	}
+	if p.RetryableErrors == nil {
+		return true
+	}
+	for _, retryable := range p.RetryableErrors {
+		if class == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+//retryPolicy returns the effective policy for the request. A policy set in the context
+//(e.g. by an Experiment) overrides the policy of the host, which overrides the policy
+//of the method, which overrides the options of the client. WithNoRetry limits any of them
+//to a single attempt.
+func (c *FailAwareHTTPClient) retryPolicy(req *http.Request) RetryPolicy {
+	policy := c.options().retryPolicy()
+	if methodPolicy, ok := c.options().MethodPolicies[req.Method]; ok {
+		policy = methodPolicy.withDefaults(policy)
+	}
+	if hostPolicy, ok := c.hostPolicy(req); ok {
+		policy = hostPolicy.withDefaults(policy)
+	}
+	if override, ok := req.Context().Value(retryPolicyKey).(RetryPolicy); ok {
+		policy = override.withDefaults(policy)
+	}
+	if noRetry(req.Context()) {
+		policy.MaxAttempts = 1
+	}
+	return policy
+}
+
+//retryPolicy returns the retry policy of the options, which the policies per method and host
+//and in the context override.
+func (o *FailAwareHTTPOptions) retryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:         o.MaxRetries,
+		MaxAttempts:        o.MaxAttempts,
+		BackOffDelayFactor: o.BackOffDelayFactor,
+		DisableJitter:      o.DisableJitter,
+		Timeout:            o.Timeout,
+		RetryableErrors:    o.RetryableErrors,
+		MaxBackOff:         o.MaxBackOff,
+		Jitter:             o.Jitter,
+		BackOffStrategy:    o.BackOffStrategy,
+		RetryImmediately:   o.RetryImmediately,
+	}
+}
+
+func (c *FailAwareHTTPClient) hostPolicy(req *http.Request) (RetryPolicy, bool) {
+	if len(c.options().HostPolicies) == 0 {
+		return RetryPolicy{}, false
+	}
+	if policy, ok := c.options().HostPolicies[req.URL.Host]; ok {
+		return policy, true
+	}
+	policy, ok := c.options().HostPolicies[req.URL.Hostname()]
+	return policy, ok
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodPolicies(t *testing.T) {
+	var hits int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(409)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	opts := optionsWithMinTimeouts()
+	opts.MethodPolicies = map[string]RetryPolicy{
+		"GET":    {MaxRetries: 5, RetryableStatuses: []int{409}},
+		"DELETE": {MaxRetries: 2, RetryableStatuses: []int{409}},
+	}
+	client := NewClient(opts)
+
+	cases := map[string]int32{"GET": 5, "DELETE": 2, "PUT": 1}
+	for method, expectedHits := range cases {
+		atomic.StoreInt32(&hits, 0)
+		req, err := http.NewRequest(method, url, nil)
+		assert.Nil(t, err)
+		rsp, err := client.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, 409, rsp.StatusCode)
+		assert.Equal(t, expectedHits, atomic.LoadInt32(&hits), method)
+	}
+}
+
+func TestHostPolicies(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte("slow"))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.HostPolicies = map[string]RetryPolicy{
+		fmt.Sprintf("localhost:%d", port): {Timeout: time.Second},
+		"localhost":                       {MaxRetries: 1},
+	}
+	client := NewClient(opts)
+
+	rsp, err := client.Get(fmt.Sprintf("http://localhost:%d/report", port))
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "slow", string(body))
+
+	rsp, err = client.Get(fmt.Sprintf("http://127.0.0.1:%d/report", port))
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, err.(FailAwareHTTPError).Retries)
+
+	_, err = client.Get(nonExistingURL)
+	assert.Equal(t, 1, err.(FailAwareHTTPError).Retries)
+}
+
+func TestPolicyWithDefaults(t *testing.T) {
+	defaults := RetryPolicy{MaxRetries: 3, RetryableStatuses: []int{503}}
+	policy := RetryPolicy{DisableJitter: true}.withDefaults(defaults)
+	assert.Equal(t, 3, policy.MaxRetries)
+	assert.True(t, policy.DisableJitter)
+	assert.True(t, policy.retryable(503))
+	assert.False(t, policy.retryable(500))
+	assert.True(t, RetryPolicy{}.retryable(500))
+}
+
+func TestDefaultRetryableStatuses(t *testing.T) {
+	for _, status := range []int{408, 425, 429, 500, 502, 503, 504} {
+		assert.True(t, retryableStatus(status), "status %d", status)
+	}
+	for _, status := range []int{200, 400, 401, 404, 409, 501, 505} {
+		assert.False(t, retryableStatus(status), "status %d", status)
+	}
+}
+
+func TestRetryableStatusesOverride501(t *testing.T) {
+	policy := RetryPolicy{RetryableStatuses: []int{501, 503}}
+	assert.True(t, policy.retryable(501))
+	assert.False(t, policy.retryable(505))
+}
+
+func TestMaxAttempts(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.MaxAttempts = 1
+	clock := newFakeClock()
+	opts.Clock = clock
+	_, err := NewClient(opts).Get(nonExistingURL)
+	assert.Equal(t, 1, err.(FailAwareHTTPError).Retries)
+	assert.Equal(t, KindRetriesExhausted, err.(FailAwareHTTPError).Kind)
+	assert.Empty(t, clock.waits, "no back off after the only attempt")
+	assert.Equal(t, 1, RetryPolicy{MaxRetries: 5, MaxAttempts: 1}.attempts())
+	assert.Equal(t, 5, RetryPolicy{MaxRetries: 5}.attempts())
+	assert.Equal(t, 3, RetryPolicy{MaxRetries: 3}.withDefaults(RetryPolicy{MaxAttempts: 7}).attempts())
+}
+
+func TestRetryForever(t *testing.T) {
+	var requests int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxAttempts = RetryForever
+	opts.MaxBackOff = 2 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+
+	rsp, err := NewClient(opts).Do(req)
+	if err == nil {
+		//retrying stopped before the deadline of the request
+		assert.Equal(t, 503, rsp.StatusCode)
+	} else {
+		//the deadline passed during an attempt
+		assert.Equal(t, KindCanceled, err.(FailAwareHTTPError).Kind)
+	}
+	assert.True(t, atomic.LoadInt32(&requests) > 5)
+}
+
+func TestSkipRetryAfterDeadline(t *testing.T) {
+	var requests int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would fail with a transport error
+	opts.Timeout = time.Second
+	opts.BackOffStrategy = BackOffConstant
+	opts.BackOffDelayFactor = time.Second
+	opts.DisableJitter = true
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+
+	started := time.Now()
+	rsp, err := NewClient(opts).Do(req)
+	assert.Nil(t, err, "a retryable status is returned as response")
+	assert.Equal(t, 503, rsp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.True(t, time.Since(started) < 250*time.Millisecond, "no back off slept")
+
+	_, err = NewClient(opts).Do(mustRequestWithContext(t, ctx, nonExistingURL))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindDeadlineExceeded, failErr.Kind)
+	assert.Equal(t, 1, len(failErr.Errors))
+}
+
+func mustRequestWithContext(t *testing.T, ctx context.Context, url string) *http.Request {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestAttemptTimeoutOverride(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxAttempts = 1
+	client := NewClient(opts)
+
+	_, err = client.Do(mustRequestWithContext(t, context.Background(), url))
+	assert.NotNil(t, err, "the client timeout is shorter than the response time")
+
+	ctx := WithAttemptTimeout(context.Background(), time.Second)
+	rsp, err := client.Do(mustRequestWithContext(t, ctx, url))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+
+	ctx = WithUnsafeRetry(WithAttemptTimeout(context.Background(), time.Minute))
+	assert.Equal(t, time.Minute, client.retryPolicy(mustRequestWithContext(t, ctx, url)).Timeout)
+}
+
+func TestNoRetry(t *testing.T) {
+	calls := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.ReturnLastResponse = true
+	client := NewClient(opts)
+
+	rsp, err := client.Do(mustRequestWithContext(t, WithNoRetry(context.Background()), url))
+	assert.Equal(t, 503, rsp.StatusCode)
+	rsp.Body.Close()
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindRetriesExhausted, err.(FailAwareHTTPError).Kind)
+		assert.True(t, errors.Is(err.(FailAwareHTTPError).LastError, ErrRetryableStatus))
+	}
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, len(clock.waits), "no back off after the only attempt")
+	assert.Equal(t, 1, client.retryPolicy(mustRequestWithContext(t, WithNoRetry(context.Background()), url)).attempts())
+}
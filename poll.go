@@ -0,0 +1,93 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//ErrStopPolling is returned by a PollHandler to end polling without an error.
+var ErrStopPolling = errors.New("stop polling")
+
+//PollHandler handles the response of a poll. Returning an error ends the polling.
+type PollHandler func(rsp *http.Response) error
+
+//PollOptions configure Poll. The zero value polls without timeout and minimum interval.
+type PollOptions struct {
+	//MinInterval is the minimum time between the start of two successful polls.
+	MinInterval time.Duration
+	//Timeout of a poll. Long polls are not limited by the timeout of the client.
+	Timeout time.Duration
+}
+
+//Poll sends the request repeatedly until ctx is done or the handler returns an error.
+//Every response is passed to the handler, unless the poll failed (a transport error or a
+//retryable status after all retries), which backs off before the next poll.
+func (c *FailAwareHTTPClient) Poll(ctx context.Context, req *http.Request, handler PollHandler, options PollOptions) error {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return err
+	}
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = -1
+	}
+	pollCtx := context.WithValue(ctx, retryPolicyKey, RetryPolicy{Timeout: timeout})
+	policy := c.retryPolicy(req.WithContext(pollCtx))
+
+	failures := 0
+	for {
+		started := c.options().Clock.Now()
+		pollReq := req.Clone(pollCtx)
+		if body != nil {
+			pollReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		rsp, err := c.Do(pollReq)
+		if ctx.Err() != nil {
+			closeBody(rsp)
+			return ctx.Err()
+		}
+
+		wait := time.Duration(0)
+		if err != nil || policy.retryable(rsp.StatusCode) {
+			closeBody(rsp)
+			wait = c.backOff(policy, failures)
+			if failures < maxReconnectBackOff {
+				failures++
+			}
+			c.options().Logger.Debugf("FAH[Debug]: poll failed, polling again in %dms", wait/time.Millisecond)
+		} else {
+			failures = 0
+			err = handler(rsp)
+			closeBody(rsp)
+			if err == ErrStopPolling {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			wait = options.MinInterval - c.options().Clock.Now().Sub(started)
+		}
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-c.options().Clock.After(wait):
+			}
+		}
+	}
+}
+
+//closeBody reads the rest of the body of the response, so its connection can be reused.
+func closeBody(rsp *http.Response) {
+	if rsp == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, rsp.Body)
+	rsp.Body.Close()
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoll(t *testing.T) {
+	requests := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			w.WriteHeader(503)
+			return
+		}
+		fmt.Fprintf(w, "update %d", requests)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 1
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+
+	var updates []string
+	err = NewClient(opts).Poll(context.Background(), req, func(rsp *http.Response) error {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		updates = append(updates, string(body))
+		if len(updates) == 3 {
+			return ErrStopPolling
+		}
+		return nil
+	}, PollOptions{MinInterval: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update 1", "update 3", "update 4"}, updates)
+}
+
+func TestPollRetryableStatusesOfPolicy(t *testing.T) {
+	requests := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			w.WriteHeader(409)
+			return
+		}
+		fmt.Fprintf(w, "update %d", requests)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 1
+	opts.MethodPolicies = map[string]RetryPolicy{"GET": {RetryableStatuses: []int{409, 503}}}
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+
+	var updates []string
+	err = NewClient(opts).Poll(context.Background(), req, func(rsp *http.Response) error {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		updates = append(updates, string(body))
+		if len(updates) == 2 {
+			return ErrStopPolling
+		}
+		return nil
+	}, PollOptions{MinInterval: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update 1", "update 3"}, updates)
+}
+
+func TestPollHandlerError(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+
+	handlerErr := fmt.Errorf("unexpected update")
+	err = NewClient(optionsWithMinTimeouts()).Poll(context.Background(), req, func(rsp *http.Response) error {
+		return handlerErr
+	}, PollOptions{})
+	assert.Equal(t, handlerErr, err)
+}
+
+func TestPollCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest("GET", nonExistingURL, nil)
+	assert.Nil(t, err)
+
+	err = NewClient(optionsWithMinTimeouts()).Poll(ctx, req, func(rsp *http.Response) error {
+		return nil
+	}, PollOptions{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+//Endpoint is a server of the endpoint pool of a client, see FailAwareHTTPOptions.Endpoints.
+type Endpoint struct {
+	//URL the relative URLs of the requests are resolved against, like BaseURL.
+	URL *url.URL
+	//Weight of the endpoint when picking the endpoint of the first attempt of a request,
+	//an endpoint with weight 9 gets 9 times the requests of one with weight 1. 1 if not set.
+	Weight int
+	//Host overrides the Host header of the requests to the endpoint, e.g. to address a pod
+	//by its IP and the service by its name. The host of URL if not set.
+	Host string
+}
+
+//OutlierDetection temporarily ejects an endpoint from the pool whose failure rate is much
+//worse than the one of the other endpoints. At most half of the endpoints are ejected at once.
+//The zero value means the defaults.
+type OutlierDetection struct {
+	//FailureRateMargin is how much the failure rate of an endpoint must exceed the mean
+	//failure rate of the other endpoints to eject it, 0.5 if not set.
+	FailureRateMargin float64
+	//MinAttempts to an endpoint within the FailureRateWindow before it can be ejected, 5 if not set.
+	MinAttempts int64
+	//EjectionTime is how long an ejected endpoint is not picked, 30 seconds if not set.
+	//Afterwards it is on probation, its failure rate starts anew.
+	EjectionTime time.Duration
+}
+
+func (o OutlierDetection) withDefaults() OutlierDetection {
+	if o.FailureRateMargin == 0 {
+		o.FailureRateMargin = 0.5
+	}
+	if o.MinAttempts == 0 {
+		o.MinAttempts = 5
+	}
+	if o.EjectionTime == 0 {
+		o.EjectionTime = 30 * time.Second
+	}
+	return o
+}
+
+type poolEndpoint struct {
+	Endpoint
+	ejectedUntil  time.Time
+	currentWeight int
+}
+
+//endpointPool picks the endpoint of every attempt. The first attempt of a request picks
+//an endpoint by smooth weighted round robin, a retry fails over to the next endpoint after
+//the one of the previous attempt regardless of the weights.
+type endpointPool struct {
+	mutex     sync.Mutex
+	endpoints []*poolEndpoint
+	//expires is when the endpoints are resolved again, only with a Resolver
+	expires time.Time
+
+	//ejectionsSynced is when the ejections of other replicas were read, see StateStore
+	ejectionsSynced time.Time
+
+	//refreshMutex lets only one request resolve the endpoints
+	refreshMutex sync.Mutex
+	resolver     Resolver
+}
+
+func newEndpointPool(endpoints []Endpoint, resolver Resolver) *endpointPool {
+	if len(endpoints) == 0 && resolver == nil {
+		return nil
+	}
+	pool := &endpointPool{resolver: resolver}
+	pool.update(endpoints)
+	return pool
+}
+
+//update replaces the endpoints of the pool, endpoints with the same URL as before keep
+//their state (e.g. their ejection). The mutex must be held.
+func (p *endpointPool) update(endpoints []Endpoint) {
+	previous := make(map[string]*poolEndpoint, len(p.endpoints))
+	for _, endpoint := range p.endpoints {
+		previous[endpoint.URL.String()] = endpoint
+	}
+	updated := make([]*poolEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.Weight <= 0 {
+			endpoint.Weight = 1
+		}
+		if kept, ok := previous[endpoint.URL.String()]; ok {
+			kept.Weight = endpoint.Weight
+			updated = append(updated, kept)
+			continue
+		}
+		updated = append(updated, &poolEndpoint{Endpoint: endpoint})
+	}
+	p.endpoints = updated
+}
+
+//pick returns the endpoint of an attempt, previous is the endpoint of the previous attempt of
+//the request or nil. Ejected endpoints are only picked if all endpoints are ejected.
+func (p *endpointPool) pick(now time.Time, previous *poolEndpoint) *poolEndpoint {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if previous == nil {
+		if endpoint := p.pickWeighted(now); endpoint != nil {
+			return endpoint
+		}
+		return p.endpoints[0]
+	}
+	start := p.index(previous) + 1
+	for i := 0; i < len(p.endpoints); i++ {
+		endpoint := p.endpoints[(start+i)%len(p.endpoints)]
+		if !now.Before(endpoint.ejectedUntil) {
+			return endpoint
+		}
+	}
+	return p.endpoints[start%len(p.endpoints)]
+}
+
+//pickWeighted picks one of the endpoints that are not ejected by smooth weighted round robin
+//(as nginx does): the picks of an endpoint are spread evenly instead of coming in bursts.
+func (p *endpointPool) pickWeighted(now time.Time) *poolEndpoint {
+	var picked *poolEndpoint
+	total := 0
+	for _, endpoint := range p.endpoints {
+		if now.Before(endpoint.ejectedUntil) {
+			continue
+		}
+		endpoint.currentWeight += endpoint.Weight
+		total += endpoint.Weight
+		if picked == nil || endpoint.currentWeight > picked.currentWeight {
+			picked = endpoint
+		}
+	}
+	if picked != nil {
+		picked.currentWeight -= total
+	}
+	return picked
+}
+
+//index returns the index of the endpoint in the pool, -1 if it is not part of the pool.
+func (p *endpointPool) index(endpoint *poolEndpoint) int {
+	for i, e := range p.endpoints {
+		if e == endpoint {
+			return i
+		}
+	}
+	return -1
+}
+
+//resolve returns the URL of the request for an attempt to the endpoint.
+func (e *poolEndpoint) resolve(u *url.URL) *url.URL {
+	return e.URL.ResolveReference(u)
+}
+
+//usesPool reports whether the attempts of the request are sent to the endpoints of the pool.
+func (c *FailAwareHTTPClient) usesPool(req *http.Request) bool {
+	return c.pool != nil && !req.URL.IsAbs()
+}
+
+//ejectOutliers ejects the endpoints whose failure rate exceeds the mean failure rate of the
+//other endpoints by the FailureRateMargin.
+func (c *FailAwareHTTPClient) ejectOutliers(ctx context.Context) {
+	if c.options().OutlierDetection == nil {
+		return
+	}
+	detection := c.options().OutlierDetection.withDefaults()
+	now := c.options().Clock.Now()
+	pool := c.pool
+
+	pool.mutex.Lock()
+	var ejected []*poolEndpoint
+	defer func() {
+		pool.mutex.Unlock()
+		for _, endpoint := range ejected {
+			c.stats.resetFailures(endpoint.URL.Host)
+			c.shareEjection(ctx, endpoint.URL.Host, endpoint.ejectedUntil, detection.EjectionTime)
+		}
+	}()
+
+	var available []*poolEndpoint
+	for _, endpoint := range pool.endpoints {
+		if !now.Before(endpoint.ejectedUntil) {
+			available = append(available, endpoint)
+		}
+	}
+	maxEjected := len(pool.endpoints)/2 - (len(pool.endpoints) - len(available))
+	if maxEjected <= 0 || len(available) < 2 {
+		return
+	}
+
+	attempts := make([]int64, len(available))
+	rates := make([]float64, len(available))
+	var rateSum float64
+	for i, endpoint := range available {
+		successes, failures := c.stats.failures(endpoint.URL.Host, now)
+		attempts[i] = successes + failures
+		if attempts[i] > 0 {
+			rates[i] = float64(failures) / float64(attempts[i])
+		}
+		rateSum += rates[i]
+	}
+	for i, endpoint := range available {
+		if len(ejected) == maxEjected {
+			return
+		}
+		peerMean := (rateSum - rates[i]) / float64(len(available)-1)
+		if attempts[i] < detection.MinAttempts || rates[i]-peerMean < detection.FailureRateMargin {
+			continue
+		}
+		c.options().Logger.Debugf("FAH[Debug]: ejecting endpoint %s, failure rate %.2f, other endpoints %.2f", endpoint.URL.Host, rates[i], peerMean)
+		endpoint.ejectedUntil = now.Add(detection.EjectionTime)
+		//re-admitted without the debt of its last picks
+		endpoint.currentWeight = 0
+		ejected = append(ejected, endpoint)
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//poolServer starts a server that answers with the status and counts its requests.
+func poolServer(t *testing.T, status int, hits *int32) Endpoint {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(status)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	u, err := url.Parse(fmt.Sprintf("http://localhost:%d/api/", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Endpoint{URL: u}
+}
+
+func TestEndpointPoolFailover(t *testing.T) {
+	var badHits, goodHits int32
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would fail over once more
+	opts.Timeout = time.Second
+	opts.Clock = newFakeClock()
+	opts.Endpoints = []Endpoint{poolServer(t, 503, &badHits), poolServer(t, 200, &goodHits)}
+	client := NewClient(opts)
+
+	for i := 0; i < 4; i++ {
+		rsp, err := client.Get("users")
+		assert.Nil(t, err)
+		assert.Equal(t, 200, rsp.StatusCode)
+	}
+	assert.Equal(t, int32(2), badHits, "every second request starts with the bad endpoint")
+	assert.Equal(t, int32(4), goodHits)
+
+	rsp, err := client.Get(opts.Endpoints[0].URL.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 503, rsp.StatusCode, "absolute URLs are not sent to the pool")
+}
+
+func TestOutlierEjection(t *testing.T) {
+	var badHits, goodHits int32
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would fail the request
+	opts.Timeout = time.Second
+	opts.MaxAttempts = 1
+	opts.Clock = clock
+	opts.Endpoints = []Endpoint{poolServer(t, 503, &badHits), poolServer(t, 200, &goodHits), poolServer(t, 200, &goodHits)}
+	opts.OutlierDetection = &OutlierDetection{MinAttempts: 3, EjectionTime: 10 * time.Second}
+	client := NewClient(opts)
+
+	for i := 0; i < 30; i++ {
+		_, err := client.Get("users")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(3), badHits, "ejected after the minimum attempts")
+	assert.Equal(t, int32(27), goodHits)
+
+	clock.After(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		_, err := client.Get("users")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(4), badHits, "re-admitted after the ejection time")
+}
+
+func TestOutlierEjectionKeepsHalf(t *testing.T) {
+	var badHits int32
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would fail the request
+	opts.Timeout = time.Second
+	opts.MaxAttempts = 1
+	opts.Clock = newFakeClock()
+	opts.Endpoints = []Endpoint{poolServer(t, 503, &badHits), poolServer(t, 503, &badHits)}
+	opts.OutlierDetection = &OutlierDetection{MinAttempts: 1}
+	client := NewClient(opts)
+
+	for i := 0; i < 10; i++ {
+		_, err := client.Get("users")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(10), badHits, "endpoints failing alike are no outliers")
+}
+
+func TestWeightedEndpoints(t *testing.T) {
+	pool := newEndpointPool([]Endpoint{{URL: &url.URL{Host: "primary"}, Weight: 9}, {URL: &url.URL{Host: "secondary"}}}, nil)
+	now := fakeClockStart
+	picks := map[string]int{}
+	for i := 0; i < 100; i++ {
+		picks[pool.pick(now, nil).URL.Host]++
+	}
+	assert.Equal(t, map[string]int{"primary": 90, "secondary": 10}, picks)
+
+	primary := pool.endpoints[0]
+	assert.Equal(t, "secondary", pool.pick(now, primary).URL.Host, "retries fail over regardless of the weights")
+
+	primary.ejectedUntil = now.Add(time.Second)
+	assert.Equal(t, "secondary", pool.pick(now, nil).URL.Host)
+	assert.Equal(t, "secondary", pool.pick(now, nil).URL.Host)
+}
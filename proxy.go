@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//proxyFunc returns the proxy selection of the transport of the client.
+func proxyFunc(options FailAwareHTTPOptions) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if noProxy(req.URL.Hostname(), options.NoProxy) {
+			return nil, nil
+		}
+		if options.ProxyURL != nil {
+			return options.ProxyURL, nil
+		}
+		if options.IgnoreProxyEnvironment {
+			return nil, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+}
+
+//noProxy reports whether the host is excluded from proxying. Entries are host names that
+//also match their subdomains ("example.com", ".example.com"), IPs, CIDR ranges or "*".
+func noProxy(host string, entries []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if ip != nil {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoProxy(t *testing.T) {
+	entries := []string{"internal.svc", ".corp.example", "10.0.0.0/8", "192.168.1.1"}
+	assert.True(t, noProxy("internal.svc", entries))
+	assert.True(t, noProxy("api.internal.svc", entries))
+	assert.True(t, noProxy("corp.example", entries))
+	assert.True(t, noProxy("git.corp.example", entries))
+	assert.True(t, noProxy("10.1.2.3", entries))
+	assert.True(t, noProxy("192.168.1.1", entries))
+	assert.False(t, noProxy("notinternal.svc", entries))
+	assert.False(t, noProxy("192.168.1.2", entries))
+	assert.True(t, noProxy("anything", []string{"*"}))
+}
+
+func TestProxyURL(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.String()))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	proxyURL, err := url.Parse(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+
+	opts := optionsWithMinTimeouts()
+	opts.ProxyURL = proxyURL
+	opts.NoProxy = []string{"direct.invalid"}
+	client := NewClient(opts)
+
+	rsp, err := client.Get("http://upstream.invalid/path")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "proxied http://upstream.invalid/path", string(body))
+
+	_, err = client.Get("http://direct.invalid/path")
+	assert.NotNil(t, err)
+}
+
+func TestIgnoreProxyEnvironment(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	assert.Nil(t, err)
+	proxy, err := proxyFunc(FailAwareHTTPOptions{IgnoreProxyEnvironment: true})(req)
+	assert.Nil(t, err)
+	assert.Nil(t, proxy)
+}
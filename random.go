@@ -0,0 +1,32 @@
+package http
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//lockedRand makes a rand.Source safe for the concurrent use by the requests of a client.
+type lockedRand struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func newLockedRand(source rand.Source) *lockedRand {
+	if source == nil {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+	return &lockedRand{rand: rand.New(source)}
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Float64()
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//unixTimeThreshold separates X-RateLimit-Reset values that are a Unix time (GitHub) from the
+//ones that are seconds until the reset: no quota resets in more than 30 years.
+const unixTimeThreshold = 1000000000
+
+//rateLimitWait returns the wait the server asks for before the next attempt, with a 429 or
+//503 response. Retry-After takes precedence, then the reset of the quota, which is only
+//waited for if the remaining quota is 0 or not sent.
+func rateLimitWait(rsp *http.Response, now time.Time) (time.Duration, bool) {
+	if rsp.StatusCode != http.StatusTooManyRequests && rsp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	if wait, ok := retryAfter(rsp.Header.Get("Retry-After"), now); ok {
+		return wait, true
+	}
+	quota, ok := parseRateLimitQuota(rsp.Header, now)
+	if !ok || (quota.hasRemaining && quota.remaining > 0) {
+		return 0, false
+	}
+	return nonNegative(quota.reset.Sub(now)), true
+}
+
+//rateLimitQuota is the quota of a host as sent with a response.
+type rateLimitQuota struct {
+	remaining    int64
+	hasRemaining bool
+	reset        time.Time
+}
+
+//parseRateLimitQuota parses the quota of the IETF draft headers (RateLimit or RateLimit-Reset
+//and RateLimit-Remaining) or else the X-RateLimit-Reset and X-RateLimit-Remaining headers.
+//ok is false without the time of the reset.
+func parseRateLimitQuota(header http.Header, now time.Time) (rateLimitQuota, bool) {
+	if quota, ok := draftRateLimitQuota(header, now); ok {
+		return quota, true
+	}
+	reset, ok := headerInt(header, "X-RateLimit-Reset")
+	if !ok {
+		return rateLimitQuota{}, false
+	}
+	var quota rateLimitQuota
+	quota.remaining, quota.hasRemaining = headerInt(header, "X-RateLimit-Remaining")
+	if reset < unixTimeThreshold {
+		quota.reset = now.Add(time.Duration(reset) * time.Second)
+	} else {
+		quota.reset = time.Unix(reset, 0)
+	}
+	return quota, true
+}
+
+//retryAfter parses a Retry-After header, either seconds or an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return nonNegative(date.Sub(now)), true
+}
+
+//draftRateLimitQuota parses the RateLimit header ("limit=100, remaining=0, reset=30" or
+//"default";r=0;t=30) or the RateLimit-Remaining and RateLimit-Reset headers, the reset
+//is in seconds.
+func draftRateLimitQuota(header http.Header, now time.Time) (rateLimitQuota, bool) {
+	var quota rateLimitQuota
+	var reset int64
+	hasReset := false
+	if fields := header.Get("RateLimit"); fields != "" {
+		//the later drafts use structured fields: "default";r=0;t=30
+		separators := func(r rune) bool { return r == ',' || r == ';' }
+		for _, field := range strings.FieldsFunc(fields, separators) {
+			parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			value, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+			if err != nil {
+				continue
+			}
+			switch strings.ToLower(parts[0]) {
+			case "r", "remaining":
+				quota.remaining, quota.hasRemaining = value, true
+			case "t", "reset":
+				reset, hasReset = value, true
+			}
+		}
+	}
+	if !hasReset {
+		reset, hasReset = headerInt(header, "RateLimit-Reset")
+		quota.remaining, quota.hasRemaining = headerInt(header, "RateLimit-Remaining")
+	}
+	if !hasReset || reset < 0 {
+		return rateLimitQuota{}, false
+	}
+	quota.reset = now.Add(time.Duration(reset) * time.Second)
+	return quota, true
+}
+
+func headerInt(header http.Header, name string) (int64, bool) {
+	value, err := strconv.ParseInt(strings.TrimSpace(header.Get(name)), 10, 64)
+	return value, err == nil
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
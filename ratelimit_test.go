@@ -0,0 +1,64 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitWait(t *testing.T) {
+	now := fakeClockStart
+	for name, test := range map[string]struct {
+		status int
+		header http.Header
+		wait   time.Duration
+		ok     bool
+	}{
+		"retry after seconds": {429, http.Header{"Retry-After": {"7"}}, 7 * time.Second, true},
+		"retry after date":    {503, http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second, true},
+		"retry after passed":  {503, http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0, true},
+		"not rate limited":    {500, http.Header{"Retry-After": {"7"}}, 0, false},
+		"draft headers":       {429, http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"12"}}, 12 * time.Second, true},
+		"draft remaining":     {429, http.Header{"Ratelimit-Remaining": {"5"}, "Ratelimit-Reset": {"12"}}, 0, false},
+		"draft field":         {429, http.Header{"Ratelimit": {"limit=100, remaining=0, reset=30"}}, 30 * time.Second, true},
+		"structured field":    {429, http.Header{"Ratelimit": {`"default";r=0;t=45`}}, 45 * time.Second, true},
+		"unix reset":          {429, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {fmt.Sprint(now.Add(time.Hour).Unix())}}, time.Hour, true},
+		"delta reset":         {429, http.Header{"X-Ratelimit-Reset": {"20"}}, 20 * time.Second, true},
+		"quota left":          {429, http.Header{"X-Ratelimit-Remaining": {"3"}, "X-Ratelimit-Reset": {"20"}}, 0, false},
+		"retry after first":   {429, http.Header{"Retry-After": {"2"}, "Ratelimit-Reset": {"12"}}, 2 * time.Second, true},
+		"no headers":          {429, http.Header{}, 0, false},
+	} {
+		wait, ok := rateLimitWait(&http.Response{StatusCode: test.status, Header: test.header}, now)
+		assert.Equal(t, test.ok, ok, name)
+		assert.Equal(t, test.wait, wait, name)
+	}
+}
+
+func TestRateLimitWaits(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", "3")
+		w.WriteHeader(429)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.RateLimitWaits = true
+	opts.MaxAttempts = 3
+	//an attempt timing out on a slow machine (e.g. with -race) would send the request again
+	opts.Timeout = time.Second
+	NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Equal(t, []time.Duration{3 * time.Second, 3 * time.Second}, clock.waits)
+
+	clock = newFakeClock()
+	opts.Clock = clock
+	opts.MaxBackOff = time.Second
+	NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Equal(t, time.Second, clock.waits[0], "capped by MaxBackOff")
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const defaultMaxRedirects = 10
+
+//checkRedirect returns the redirect policy of the embedded client. The limit of
+//MaxRedirects is checked before a custom CheckRedirect is consulted.
+func checkRedirect(options FailAwareHTTPOptions) func(req *http.Request, via []*http.Request) error {
+	maxRedirects := options.MaxRedirects
+	if maxRedirects == nullOptions.MaxRedirects {
+		maxRedirects = defaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if maxRedirects < 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if options.CheckRedirect != nil {
+			return options.CheckRedirect(req, via)
+		}
+		return nil
+	}
+}
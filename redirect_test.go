@@ -0,0 +1,79 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//redirectServer redirects /n to /n-1 until /0 is reached
+func redirectServer(t *testing.T) int {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Path[1:])
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n-1), http.StatusFound)
+			return
+		}
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	return port
+}
+
+func TestMaxRedirects(t *testing.T) {
+	port := redirectServer(t)
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would fail the redirect chain
+	opts.Timeout = time.Second
+	opts.MaxRetries = 1
+	opts.MaxRedirects = 2
+	client := NewClient(opts)
+
+	rsp, err := client.Get(fmt.Sprintf("http://localhost:%d/2", port))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+
+	_, err = client.Get(fmt.Sprintf("http://localhost:%d/3", port))
+	assert.NotNil(t, err)
+}
+
+func TestDisableRedirects(t *testing.T) {
+	port := redirectServer(t)
+	opts := optionsWithMinTimeouts()
+	opts.MaxRedirects = -1
+	client := NewClient(opts)
+
+	rsp, err := client.Get(fmt.Sprintf("http://localhost:%d/1", port))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, rsp.StatusCode)
+	assert.Equal(t, "/0", rsp.Header.Get("Location"))
+}
+
+func TestCheckRedirect(t *testing.T) {
+	port := redirectServer(t)
+	opts := optionsWithMinTimeouts()
+	opts.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if via[0].Header.Get("X-Signature") != "" {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/1", port), nil)
+	assert.Nil(t, err)
+	req.Header.Set("X-Signature", "signed")
+	rsp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, rsp.StatusCode)
+
+	rsp, err = client.Get(fmt.Sprintf("http://localhost:%d/1", port))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+}
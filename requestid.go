@@ -0,0 +1,31 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+//requestID returns the ID of the request: the one of its context, of its
+//RequestIDHeader or a new one. Without RequestIDHeader only the context is used.
+func (c *FailAwareHTTPClient) requestID(req *http.Request) string {
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return id
+	}
+	if c.options().RequestIDHeader == "" {
+		return ""
+	}
+	if id := req.Header.Get(c.options().RequestIDHeader); id != "" {
+		return id
+	}
+	return newRequestID()
+}
+
+//newRequestID returns a random ID of 32 hex characters.
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDGenerated(t *testing.T) {
+	var ids []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		w.WriteHeader(503)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	logger := &DummyFieldLogger{}
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would use up an attempt
+	opts.Timeout = time.Second
+	opts.Logger = logger
+	opts.RequestIDHeader = "X-Request-ID"
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 3, len(ids))
+	assert.Len(t, ids[0], 32)
+	assert.Equal(t, ids[0], ids[1])
+	assert.Equal(t, ids[0], ids[2])
+	for _, fields := range logger.fields {
+		assert.Equal(t, ids[0], fields["request_id"])
+	}
+}
+
+func TestRequestIDFromContextAndHeader(t *testing.T) {
+	var ids []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Correlation-ID"))
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.RequestIDHeader = "X-Correlation-ID"
+	//an attempt timing out on a slow machine (e.g. with -race) would send the request again
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	_, err = client.Do(req.WithContext(WithRequestID(context.Background(), "from-context")))
+	assert.Nil(t, err)
+
+	req.Header.Set("X-Correlation-ID", "from-header")
+	_, err = client.Do(req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"from-context", "from-header"}, ids)
+}
+
+func TestRequestIDInError(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.RequestIDHeader = "X-Request-ID"
+	req, err := http.NewRequest("GET", nonExistingURL, nil)
+	assert.Nil(t, err)
+	_, err = NewClient(opts).Do(req.WithContext(WithRequestID(context.Background(), "abc")))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", failErr.RequestID)
+}
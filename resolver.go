@@ -0,0 +1,175 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//defaultResolverTTL is how long resolved endpoints are used if the Resolver returns no TTL.
+const defaultResolverTTL = 30 * time.Second
+
+//resolveRetryInterval is how long the previous endpoints are used after a failed resolution.
+const resolveRetryInterval = time.Second
+
+//ErrNoEndpoints is returned if the Resolver of the client returned no endpoints.
+var ErrNoEndpoints = errors.New("resolver returned no endpoints")
+
+//ErrHeadlessServiceTLS is returned by a HeadlessServiceResolver with the https scheme.
+var ErrHeadlessServiceTLS = errors.New("headless service endpoints do not support https")
+
+//Resolver yields the endpoints of the pool of a client dynamically, e.g. from DNS SRV records
+//(see SRVResolver) or a service registry like Consul. The client resolves the endpoints again
+//once the TTL of the previous ones expired.
+type Resolver interface {
+	//Resolve returns the current endpoints and how long they are valid.
+	Resolve(ctx context.Context) ([]Endpoint, time.Duration, error)
+}
+
+//ResolverFunc is an adapter to use an ordinary function as Resolver.
+type ResolverFunc func(ctx context.Context) ([]Endpoint, time.Duration, error)
+
+//Resolve calls f(ctx).
+func (f ResolverFunc) Resolve(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	return f(ctx)
+}
+
+//SRVResolver resolves the endpoints from the DNS SRV records of a service. Only the records
+//with the lowest priority are used, their weights are the weights of the endpoints.
+type SRVResolver struct {
+	//Service, Proto and Name are looked up as _Service._Proto.Name, see net.LookupSRV.
+	Service string
+	Proto   string
+	Name    string
+	//Scheme of the endpoint URLs, http if not set.
+	Scheme string
+	//TTL of the resolved endpoints, 30 seconds if not set. The resolver of the standard
+	//library does not expose the TTLs of the records.
+	TTL time.Duration
+
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+//Resolve looks up the SRV records.
+func (r SRVResolver) Resolve(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	lookupSRV := r.lookupSRV
+	if lookupSRV == nil {
+		lookupSRV = net.DefaultResolver.LookupSRV
+	}
+	_, records, err := lookupSRV(ctx, r.Service, r.Proto, r.Name)
+	if err != nil {
+		return nil, 0, err
+	}
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	var endpoints []Endpoint
+	for _, record := range records {
+		//the records are sorted by priority
+		if record.Priority != records[0].Priority {
+			break
+		}
+		host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), fmt.Sprint(record.Port))
+		endpoints = append(endpoints, Endpoint{URL: &url.URL{Scheme: scheme, Host: host}, Weight: int(record.Weight)})
+	}
+	return endpoints, r.TTL, nil
+}
+
+//refreshPool resolves the endpoints of the pool again if they expired. If the resolution
+//fails, the previous endpoints are used for the resolveRetryInterval.
+func (c *FailAwareHTTPClient) refreshPool(ctx context.Context) error {
+	pool := c.pool
+	if pool.resolver == nil {
+		return nil
+	}
+	if !pool.expired(c.options().Clock.Now()) {
+		return nil
+	}
+	pool.refreshMutex.Lock()
+	defer pool.refreshMutex.Unlock()
+	if !pool.expired(c.options().Clock.Now()) {
+		//resolved by another request in the meantime
+		return nil
+	}
+
+	endpoints, ttl, err := pool.resolver.Resolve(ctx)
+	if err == nil && len(endpoints) == 0 {
+		err = ErrNoEndpoints
+	}
+	now := c.options().Clock.Now()
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	if err != nil {
+		if len(pool.endpoints) == 0 {
+			return err
+		}
+		c.options().Logger.Debugf("FAH[Debug]: resolving endpoints failed, using the previous ones: %s", err)
+		pool.expires = now.Add(resolveRetryInterval)
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = defaultResolverTTL
+	}
+	pool.update(endpoints)
+	pool.expires = now.Add(ttl)
+	return nil
+}
+
+func (p *endpointPool) expired(now time.Time) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.endpoints) == 0 || !now.Before(p.expires)
+}
+
+//HeadlessServiceResolver resolves the endpoints from all addresses of a host, e.g. the pods of
+//a Kubernetes headless service. Every address is an endpoint of its own, so the retries of a
+//request fail over to another pod instead of reusing a connection to the same pod.
+//The requests keep the name of the service as Host header. https is not supported: TLS would
+//verify the certificate of a pod, which is issued for the name of the service, against the
+//address of the pod.
+type HeadlessServiceResolver struct {
+	//Service is the DNS name of the service, e.g. api.default.svc.cluster.local.
+	Service string
+	//Port of the pods.
+	Port int
+	//Scheme of the endpoint URLs, http if not set. https fails with ErrHeadlessServiceTLS.
+	Scheme string
+	//TTL of the resolved endpoints, 30 seconds if not set. CoreDNS serves the records of
+	//services with a TTL of 5 seconds by default, a similar TTL notices new pods early.
+	TTL time.Duration
+
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+//Resolve looks up the addresses of the service.
+func (r HeadlessServiceResolver) Resolve(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	if strings.EqualFold(r.Scheme, "https") {
+		return nil, 0, ErrHeadlessServiceTLS
+	}
+	lookupHost := r.lookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+	addrs, err := lookupHost(ctx, r.Service)
+	if err != nil {
+		return nil, 0, err
+	}
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	port := fmt.Sprint(r.Port)
+	endpoints := make([]Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		endpoints = append(endpoints, Endpoint{
+			URL:  &url.URL{Scheme: scheme, Host: net.JoinHostPort(addr, port)},
+			Host: net.JoinHostPort(r.Service, port),
+		})
+	}
+	return endpoints, r.TTL, nil
+}
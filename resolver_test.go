@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRVResolver(t *testing.T) {
+	resolver := SRVResolver{Service: "api", Proto: "tcp", Name: "example.com", Scheme: "https"}
+	resolver.lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		assert.Equal(t, "api", service)
+		return "_api._tcp.example.com.", []*net.SRV{
+			{Target: "a.example.com.", Port: 8443, Priority: 1, Weight: 90},
+			{Target: "b.example.com.", Port: 8443, Priority: 1, Weight: 10},
+			{Target: "backup.example.com.", Port: 8443, Priority: 2, Weight: 100},
+		}, nil
+	}
+
+	endpoints, ttl, err := resolver.Resolve(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.Equal(t, []Endpoint{
+		{URL: &url.URL{Scheme: "https", Host: "a.example.com:8443"}, Weight: 90},
+		{URL: &url.URL{Scheme: "https", Host: "b.example.com:8443"}, Weight: 10},
+	}, endpoints)
+}
+
+func TestResolverRefreshesPool(t *testing.T) {
+	var firstHits, secondHits int32
+	first := poolServer(t, 200, &firstHits)
+	second := poolServer(t, 200, &secondHits)
+	var resolved int32
+	var resolveErr error
+	current := []Endpoint{first}
+
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.Resolver = ResolverFunc(func(ctx context.Context) ([]Endpoint, time.Duration, error) {
+		atomic.AddInt32(&resolved, 1)
+		return current, 10 * time.Second, resolveErr
+	})
+	client := NewClient(opts)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get("users")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(1), resolved)
+	assert.Equal(t, int32(3), firstHits)
+
+	current = []Endpoint{second}
+	clock.After(10 * time.Second)
+	_, err := client.Get("users")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), resolved)
+	assert.Equal(t, int32(1), secondHits, "the pool was refreshed after the TTL")
+
+	resolveErr = errors.New("registry down")
+	clock.After(10 * time.Second)
+	_, err = client.Get("users")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), secondHits, "the previous endpoints are used if resolving fails")
+
+	opts.Resolver = ResolverFunc(func(ctx context.Context) ([]Endpoint, time.Duration, error) {
+		return nil, 0, nil
+	})
+	_, err = NewClient(opts).Get("users")
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, KindPrepareFailed, failErr.Kind)
+	assert.Equal(t, ErrNoEndpoints, failErr.LastError)
+}
+
+func TestHeadlessServiceResolver(t *testing.T) {
+	var hosts []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	resolver := HeadlessServiceResolver{Service: "api.default.svc.cluster.local", Port: port}
+	resolver.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		assert.Equal(t, "api.default.svc.cluster.local", host)
+		return []string{"127.0.0.1", "::1"}, nil
+	}
+
+	endpoints, _, err := resolver.Resolve(context.Background())
+	assert.Nil(t, err)
+	service := fmt.Sprintf("api.default.svc.cluster.local:%d", port)
+	assert.Equal(t, []Endpoint{
+		{URL: &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}, Host: service},
+		{URL: &url.URL{Scheme: "http", Host: fmt.Sprintf("[::1]:%d", port)}, Host: service},
+	}, endpoints)
+
+	opts := optionsWithMinTimeouts()
+	opts.Resolver = resolver
+	_, err = NewClient(opts).Get("/health")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{service}, hosts, "the pod is addressed by its IP, the service by its name")
+
+	resolver.Scheme = "https"
+	_, _, err = resolver.Resolve(context.Background())
+	assert.Equal(t, ErrHeadlessServiceTLS, err)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+//errResponseChanged is returned by a resume if the response is not the one that was interrupted.
+var errResponseChanged = errors.New("response changed between attempts")
+
+//DoResumable does the request like Do. If reading the body of the response fails midway
+//(e.g. connection reset or unexpected EOF), the request is sent again and the body continues
+//where it was interrupted, up to MaxAttempts times. Only idempotent requests are resumed.
+//If the server supports range requests (Accept-Ranges: bytes), only the missing bytes are
+//requested, otherwise the bytes already read are skipped.
+func (c *FailAwareHTTPClient) DoResumable(req *http.Request) (*http.Response, error) {
+	if !retryAllowed(req, c.options().AllowUnsafeRetry) {
+		return c.Do(req)
+	}
+	if req.Body != nil && req.GetBody == nil {
+		body, err := readBody(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	rsp, err := c.Do(req)
+	if err != nil || rsp.StatusCode != http.StatusOK {
+		return rsp, err
+	}
+	rsp.Body = &resumingBody{
+		client:     c,
+		req:        req,
+		body:       rsp.Body,
+		etag:       rsp.Header.Get("ETag"),
+		ranges:     rsp.Header.Get("Accept-Ranges") == "bytes",
+		length:     rsp.ContentLength,
+		maxResumes: c.retryPolicy(req).attempts(),
+	}
+	return rsp, nil
+}
+
+//resumingBody is a response body that resumes after read errors by sending the request
+//again and skipping the bytes already read.
+type resumingBody struct {
+	client     *FailAwareHTTPClient
+	req        *http.Request
+	body       io.ReadCloser
+	etag       string
+	ranges     bool
+	length     int64
+	offset     int64
+	resumes    int
+	maxResumes int
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+	if err == nil || err == io.EOF || (b.maxResumes >= 0 && b.resumes >= b.maxResumes) || b.req.Context().Err() != nil {
+		return n, err
+	}
+	if resumeErr := b.resume(); resumeErr != nil {
+		b.client.options().Logger.Debugf("FAH[Debug]: resuming response body failed: %s", resumeErr)
+		return n, err
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return b.Read(p)
+}
+
+func (b *resumingBody) Close() error {
+	return b.body.Close()
+}
+
+//resume replaces the interrupted body with the body of a new response, positioned at the offset.
+func (b *resumingBody) resume() error {
+	b.resumes++
+	b.body.Close()
+	b.client.options().Logger.Debugf("FAH[Debug]: resuming response body at byte %d", b.offset)
+
+	req := b.req.Clone(b.req.Context())
+	if b.req.GetBody != nil {
+		body, err := b.req.GetBody()
+		if err != nil {
+			return err
+		}
+		req.Body = body
+	}
+	if b.ranges && b.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+		if b.etag != "" {
+			req.Header.Set("If-Range", b.etag)
+		}
+	}
+	rsp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode == http.StatusPartialContent {
+		if err := b.checkContentRange(rsp.Header.Get("Content-Range")); err != nil {
+			rsp.Body.Close()
+			return err
+		}
+		b.body = rsp.Body
+		return nil
+	}
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("ETag") != b.etag {
+		rsp.Body.Close()
+		return fmt.Errorf("%w: status %d, etag %q", errResponseChanged, rsp.StatusCode, rsp.Header.Get("ETag"))
+	}
+	if _, err := io.CopyN(ioutil.Discard, rsp.Body, b.offset); err != nil {
+		rsp.Body.Close()
+		return err
+	}
+	b.body = rsp.Body
+	return nil
+}
+
+//checkContentRange verifies that a partial response continues at the offset of the body.
+func (b *resumingBody) checkContentRange(contentRange string) error {
+	var start, end, length int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &length); err != nil {
+		return fmt.Errorf("%w: invalid Content-Range %q", errResponseChanged, contentRange)
+	}
+	if start != b.offset || (b.length >= 0 && length != b.length) {
+		return fmt.Errorf("%w: Content-Range %q does not continue at byte %d", errResponseChanged, contentRange, b.offset)
+	}
+	return nil
+}
+
+//Download writes the body of a GET request to w and returns the number of bytes written.
+//Interrupted transfers are resumed, see DoResumable.
+func (c *FailAwareHTTPClient) Download(ctx context.Context, url string, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	rsp, err := c.DoResumable(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("download failed with status %d", rsp.StatusCode)
+	}
+	return io.Copy(w, rsp.Body)
+}
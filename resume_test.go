@@ -0,0 +1,120 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//interruptingServer sends the first interruptions responses only partially
+func interruptingServer(t *testing.T, content string, interruptions int32) (int, *int32) {
+	var requests int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("ETag", `"v1"`)
+		if n > interruptions {
+			w.Write([]byte(content))
+			return
+		}
+		w.Write([]byte(content[:len(content)/2]))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	return port, &requests
+}
+
+func TestDoResumable(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	port, requests := interruptingServer(t, content, 2)
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would send another request
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	rsp, err := client.DoResumable(req)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+}
+
+func TestDoResumableGivesUp(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	port, requests := interruptingServer(t, content, 10)
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would send another request
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	rsp, err := client.DoResumable(req)
+	assert.Nil(t, err)
+	_, err = ioutil.ReadAll(rsp.Body)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(requests), "first request and 3 resumes")
+}
+
+func TestDownloadWithRanges(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	var mutex sync.Mutex
+	var ranges []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		requests := len(ranges)
+		mutex.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Accept-Ranges", "bytes")
+		if requests == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content[:3000]))
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	var out bytes.Buffer
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would send the range request again
+	opts.Timeout = time.Second
+	n, err := NewClient(opts).Download(context.Background(), fmt.Sprintf("http://localhost:%d", port), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(content)), n)
+	assert.Equal(t, content, out.String())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"", "bytes=3000-"}, ranges)
+}
+
+func TestCheckContentRange(t *testing.T) {
+	body := &resumingBody{offset: 3000, length: 10000}
+	assert.Nil(t, body.checkContentRange("bytes 3000-9999/10000"))
+	assert.NotNil(t, body.checkContentRange("bytes 0-9999/10000"))
+	assert.NotNil(t, body.checkContentRange("bytes 3000-9999/20000"))
+	assert.NotNil(t, body.checkContentRange("invalid"))
+}
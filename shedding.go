@@ -0,0 +1,189 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrShed is the LastError of a request that was shed, see FailAwareHTTPOptions.MaxConcurrentRequests.
+var ErrShed = errors.New("request shed at the concurrency limit")
+
+//ErrQueueTimeout is the LastError of a request that could not start within the
+//FailAwareHTTPOptions.MaxQueueWait.
+var ErrQueueTimeout = errors.New("request queued at the concurrency limit for too long")
+
+//Priority of a request, see WithPriority. Under load the requests with a lower priority are
+//shed first.
+type Priority int
+
+const (
+	//PriorityLow requests may use half of the concurrency limit and retry budget, e.g. prefetching.
+	PriorityLow Priority = -1
+	//PriorityNormal requests may use 90% of the concurrency limit and retry budget.
+	PriorityNormal Priority = 0
+	//PriorityHigh requests may use all of the concurrency limit and retry budget.
+	PriorityHigh Priority = 1
+)
+
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	}
+	return "normal"
+}
+
+//share returns how much of a limit requests with the priority may use, at least 1.
+func (p Priority) share(limit int64) int64 {
+	switch {
+	case p < PriorityNormal:
+		return (limit + 1) / 2
+	case p == PriorityNormal:
+		return (limit*9 + 9) / 10
+	}
+	return limit
+}
+
+//limiterWaiter is a request queued for a slot, ready is closed once it got one.
+type limiterWaiter struct {
+	priority Priority
+	ready    chan struct{}
+}
+
+//concurrencyLimiter counts the requests in flight, they are counted without a limit too,
+//so that the limit can be set with UpdateOptions. The requests queued for a slot are
+//ordered by priority, then by their arrival.
+type concurrencyLimiter struct {
+	mutex    sync.Mutex
+	inFlight int64
+	waiters  []*limiterWaiter
+}
+
+//admits reports whether a request with the priority can be admitted now, the mutex must be held.
+func (l *concurrencyLimiter) admits(limit int64, priority Priority) bool {
+	return limit <= 0 || l.inFlight < priority.share(limit)
+}
+
+//acquire admits a request if the requests in flight are below the share of the limit for
+//its priority. Otherwise it waits up to maxWait for a slot, or fails at once without maxWait.
+//Always admitted if limit is not positive.
+func (l *concurrencyLimiter) acquire(ctx context.Context, clock Clock, limit int64, priority Priority, maxWait time.Duration) error {
+	l.mutex.Lock()
+	//the limit may have been raised since the last release
+	l.admitWaiters(limit)
+	if l.admits(limit, priority) {
+		l.inFlight++
+		l.mutex.Unlock()
+		return nil
+	}
+	if maxWait <= 0 {
+		l.mutex.Unlock()
+		return ErrShed
+	}
+	waiter := &limiterWaiter{priority: priority, ready: make(chan struct{})}
+	position := len(l.waiters)
+	for position > 0 && l.waiters[position-1].priority < priority {
+		position--
+	}
+	l.waiters = append(l.waiters, nil)
+	copy(l.waiters[position+1:], l.waiters[position:])
+	l.waiters[position] = waiter
+	l.mutex.Unlock()
+
+	var expired <-chan time.Time
+	if _, ok := clock.(realClock); ok {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		expired = timer.C
+	} else {
+		expired = clock.After(maxWait)
+	}
+	var err error
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-expired:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i, queued := range l.waiters {
+		if queued == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return err
+		}
+	}
+	//got a slot in the meantime
+	return nil
+}
+
+//admitWaiters hands the free slots to the queued requests, the mutex must be held. A waiter
+//whose priority does not admit it lets the ones with a higher priority pass.
+func (l *concurrencyLimiter) admitWaiters(limit int64) {
+	for i := 0; i < len(l.waiters); {
+		waiter := l.waiters[i]
+		if !l.admits(limit, waiter.priority) {
+			i++
+			continue
+		}
+		l.inFlight++
+		close(waiter.ready)
+		l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+	}
+}
+
+func (l *concurrencyLimiter) release(limit int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight--
+	l.admitWaiters(limit)
+}
+
+//admit admits the request with the context, see FailAwareHTTPOptions.MaxConcurrentRequests.
+//release must be called once the request is done.
+func (c *FailAwareHTTPClient) admit(ctx context.Context) (release func(), err error) {
+	priority := PriorityFromContext(ctx)
+	options := c.options()
+	err = c.limiter.acquire(ctx, options.Clock, int64(options.MaxConcurrentRequests), priority, options.MaxQueueWait)
+	if err != nil {
+		c.options().Logger.Debugf("FAH[Debug]: shedding request with %s priority, %d requests in flight: %s", priority, c.inFlight(), err)
+		return nil, err
+	}
+	return func() {
+		c.limiter.release(int64(c.options().MaxConcurrentRequests))
+	}, nil
+}
+
+//startRetrying reports whether the request with the context may start retrying, see
+//FailAwareHTTPOptions.MaxConcurrentRetries. stopRetrying must be called once it is done.
+func (c *FailAwareHTTPClient) startRetrying(ctx context.Context) bool {
+	priority := PriorityFromContext(ctx)
+	options := c.options()
+	if err := c.retryLimiter.acquire(ctx, options.Clock, int64(options.MaxConcurrentRetries), priority, 0); err != nil {
+		c.options().Logger.Debugf("FAH[Debug]: not retrying request with %s priority, %d requests retrying", priority, c.retrying())
+		return false
+	}
+	return true
+}
+
+func (c *FailAwareHTTPClient) stopRetrying() {
+	c.retryLimiter.release(int64(c.options().MaxConcurrentRetries))
+}
+
+func (c *FailAwareHTTPClient) retrying() int64 {
+	c.retryLimiter.mutex.Lock()
+	defer c.retryLimiter.mutex.Unlock()
+	return c.retryLimiter.inFlight
+}
+
+func (c *FailAwareHTTPClient) inFlight() int64 {
+	c.limiter.mutex.Lock()
+	defer c.limiter.mutex.Unlock()
+	return c.limiter.inFlight
+}
@@ -0,0 +1,207 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityShare(t *testing.T) {
+	assert.Equal(t, int64(5), PriorityLow.share(10))
+	assert.Equal(t, int64(9), PriorityNormal.share(10))
+	assert.Equal(t, int64(10), PriorityHigh.share(10))
+	assert.Equal(t, int64(1), PriorityLow.share(1), "a limit of 1 admits every priority")
+	assert.Equal(t, int64(1), PriorityNormal.share(1))
+}
+
+func TestRetryBudgetByPriority(t *testing.T) {
+	ctx := context.WithValue(context.Background(), retryBudgetKey, &retryBudget{size: 4, remaining: 4})
+	low := WithPriority(ctx, PriorityLow)
+	assert.True(t, takeRetryBudget(low))
+	assert.True(t, takeRetryBudget(low))
+	assert.False(t, takeRetryBudget(low), "half of the budget is left for higher priorities")
+	assert.True(t, takeRetryBudget(ctx))
+	assert.True(t, takeRetryBudget(WithPriority(ctx, PriorityHigh)))
+	assert.False(t, takeRetryBudget(WithPriority(ctx, PriorityHigh)))
+}
+
+func TestShedLowPriorityFirst(t *testing.T) {
+	received := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-unblock
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = time.Second
+	opts.MaxConcurrentRequests = 2
+	client := NewClient(opts)
+
+	results := make(chan error, 2)
+	go func() {
+		rsp, err := client.Get(url)
+		if err == nil {
+			rsp.Body.Close()
+		}
+		results <- err
+	}()
+	<-received
+
+	_, err = client.Do(mustRequestWithContext(t, WithPriority(context.Background(), PriorityLow), url))
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindShed, err.(FailAwareHTTPError).Kind)
+		assert.Equal(t, ErrShed, err.(FailAwareHTTPError).LastError)
+	}
+
+	go func() {
+		rsp, err := client.Do(mustRequestWithContext(t, WithPriority(context.Background(), PriorityHigh), url))
+		if err == nil {
+			rsp.Body.Close()
+		}
+		results <- err
+	}()
+	<-received
+	close(unblock)
+	assert.Nil(t, <-results)
+	assert.Nil(t, <-results)
+	assert.Equal(t, int64(0), client.inFlight())
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	limiter := &concurrencyLimiter{}
+	clock := realClock{}
+	assert.Nil(t, limiter.acquire(context.Background(), clock, 1, PriorityNormal, 0))
+	assert.Equal(t, ErrShed, limiter.acquire(context.Background(), clock, 1, PriorityNormal, 0))
+	assert.Equal(t, ErrQueueTimeout, limiter.acquire(context.Background(), clock, 1, PriorityNormal, 10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, limiter.acquire(ctx, clock, 1, PriorityNormal, time.Minute))
+
+	admitted := make(chan Priority, 2)
+	for _, priority := range []Priority{PriorityLow, PriorityHigh} {
+		go func(priority Priority) {
+			if limiter.acquire(context.Background(), clock, 1, priority, time.Minute) == nil {
+				admitted <- priority
+			}
+		}(priority)
+		for queued(limiter) == 0 || (priority == PriorityHigh && queued(limiter) < 2) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	limiter.release(1)
+	assert.Equal(t, PriorityHigh, <-admitted, "the free slot goes to the higher priority")
+	limiter.release(1)
+	assert.Equal(t, PriorityLow, <-admitted)
+	limiter.release(1)
+	assert.Equal(t, int64(0), limiter.inFlight)
+}
+
+func TestQueueTimeout(t *testing.T) {
+	received := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-unblock
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = time.Second
+	opts.MaxConcurrentRequests = 1
+	opts.MaxQueueWait = 20 * time.Millisecond
+	client := NewClient(opts)
+
+	results := make(chan error, 2)
+	go func() {
+		rsp, err := client.Get(url)
+		if err == nil {
+			rsp.Body.Close()
+		}
+		results <- err
+	}()
+	<-received
+
+	_, err = client.Get(url)
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindQueueTimeout, err.(FailAwareHTTPError).Kind)
+		assert.Equal(t, ErrQueueTimeout, err.(FailAwareHTTPError).LastError)
+	}
+
+	client.UpdateOptions(func(options *FailAwareHTTPOptions) {
+		options.MaxQueueWait = time.Second
+	})
+	go func() {
+		rsp, err := client.Get(url)
+		if err == nil {
+			rsp.Body.Close()
+		}
+		results <- err
+	}()
+	for queued(client.limiter) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+	assert.Nil(t, <-results)
+	assert.Nil(t, <-results, "the queued request starts once the first one is done")
+}
+
+func queued(limiter *concurrencyLimiter) int {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return len(limiter.waiters)
+}
+
+func TestMaxConcurrentRetries(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = time.Second
+	opts.MaxAttempts = 2
+	opts.BackOffDelayFactor = 200 * time.Millisecond
+	opts.DisableJitter = true
+	opts.ReturnLastResponse = true
+	opts.MaxConcurrentRetries = 1
+	client := NewClient(opts)
+
+	results := make(chan error, 1)
+	go func() {
+		rsp, err := client.Get(url)
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+		results <- err
+	}()
+	for client.retrying() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	rsp, err := client.Get(url)
+	assert.Equal(t, 503, rsp.StatusCode)
+	rsp.Body.Close()
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindTooManyRetries, err.(FailAwareHTTPError).Kind)
+		assert.Equal(t, 0, err.(FailAwareHTTPError).Retries)
+	}
+
+	err = <-results
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindRetriesExhausted, err.(FailAwareHTTPError).Kind)
+	}
+	assert.Equal(t, int64(0), client.retrying())
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+//ErrClientClosed is returned for requests sent after Close was called.
+var ErrClientClosed = errors.New("client closed")
+
+//requestTracker keeps track of the requests in flight, so that Close can wait for them
+//or cancel them.
+type requestTracker struct {
+	mutex    sync.Mutex
+	closed   bool
+	nextID   int64
+	inFlight map[int64]context.CancelFunc
+	//idle is closed when the last request in flight finished after Close
+	idle chan struct{}
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{inFlight: map[int64]context.CancelFunc{}}
+}
+
+//start registers a request. It returns the context of the request, which Close cancels,
+//and the function to call when the retries of the request are done.
+func (t *requestTracker) start(parent context.Context) (context.Context, context.CancelFunc, func(), error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return nil, nil, nil, ErrClientClosed
+	}
+	id := t.nextID
+	t.nextID++
+	ctx, cancel := context.WithCancel(parent)
+	t.inFlight[id] = cancel
+	finish := func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		delete(t.inFlight, id)
+		if len(t.inFlight) == 0 && t.idle != nil {
+			close(t.idle)
+			t.idle = nil
+		}
+	}
+	return ctx, cancel, finish, nil
+}
+
+//close stops accepting requests and returns a channel that is closed once no request
+//is in flight anymore.
+func (t *requestTracker) close() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.closed = true
+	if len(t.inFlight) == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	return t.idle
+}
+
+func (t *requestTracker) cancelAll() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, cancel := range t.inFlight {
+		cancel()
+	}
+}
+
+//Close shuts the client down: new requests fail with ErrClientClosed, the requests in
+//flight may finish their retries until ctx is done, then they are canceled. Finally the
+//idle connections are closed, the ones of clients sharing the transport too (see WithOptions).
+//It returns the error of ctx if requests had to be canceled. The bodies of responses returned
+//before can still be read.
+func (c *FailAwareHTTPClient) Close(ctx context.Context) error {
+	idle := c.tracker.close()
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+		c.options().Logger.Debugf("FAH[Debug]: canceling the requests in flight on close: %s", err)
+		c.tracker.cancelAll()
+		<-idle
+	}
+	c.httpClient.CloseIdleConnections()
+	return err
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseRejectsNewRequests(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	client := NewClient(optionsWithMinTimeouts())
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	rsp, err := client.Get(url)
+	assert.Nil(t, err)
+	rsp.Body.Close()
+	assert.Nil(t, client.Close(context.Background()))
+
+	rsp, err = client.Get(url)
+	assert.Nil(t, rsp)
+	assert.Equal(t, ErrClientClosed, err)
+}
+
+func TestCloseWaitsForRequestsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	result := make(chan error, 1)
+	go func() {
+		rsp, err := client.Get(fmt.Sprintf("http://localhost:%d", port))
+		if err == nil {
+			rsp.Body.Close()
+		}
+		result <- err
+	}()
+	<-started
+	assert.Nil(t, client.Close(context.Background()))
+	assert.Nil(t, <-result, "the request in flight finished")
+}
+
+func TestCloseCancelsRetriesAfterDeadline(t *testing.T) {
+	attempts := make(chan struct{}, 100)
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.MaxAttempts = RetryForever
+	opts.MaxBackOff = 10 * time.Millisecond
+	client := NewClient(opts)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Get(fmt.Sprintf("http://localhost:%d", port))
+		result <- err
+	}()
+	<-attempts
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, client.Close(ctx))
+
+	err = <-result
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindCanceled, err.(FailAwareHTTPError).Kind)
+	}
+}
@@ -0,0 +1,159 @@
+//Package sigv4 signs requests with AWS Signature Version 4. Use Signer.Sign as
+//FailAwareHTTPOptions.SignRequest to sign every attempt with a fresh timestamp.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+//ErrMissingCredentials is returned if the access key or the secret key is not set.
+var ErrMissingCredentials = errors.New("sigv4: missing credentials")
+
+//Signer signs requests for a service in a region.
+type Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	//SessionToken of temporary credentials, sent as X-Amz-Security-Token.
+	SessionToken string
+	Region       string
+	Service      string
+	//Now is the time of the signature, time.Now if not set.
+	Now func() time.Time
+}
+
+//Sign adds the X-Amz-Date and Authorization headers to the request. body is the
+//complete request body, its hash is part of the signature.
+func (s Signer) Sign(req *http.Request, body []byte) error {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return ErrMissingCredentials
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+
+	payloadHash := hashHex(body)
+	req.Header.Set("X-Amz-Date", t.Format(timeFormat))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(dateFormat), s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{algorithm, t.Format(timeFormat), scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), t.Format(dateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+//canonicalHeaders returns the signed headers (host, content-type and x-amz-*), each
+//followed by a newline, and the list of their names.
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, vs := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+	return headers.String(), strings.Join(names, ";")
+}
+
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+//escape encodes like RFC 3986, which differs from url.QueryEscape in space, * and ~.
+func escape(s string) string {
+	escaped := url.QueryEscape(s)
+	escaped = strings.Replace(escaped, "+", "%20", -1)
+	escaped = strings.Replace(escaped, "*", "%2A", -1)
+	return strings.Replace(escaped, "%7E", "~", -1)
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,60 @@
+package sigv4
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func exampleSigner() Signer {
+	return Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		Now: func() time.Time {
+			return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+		},
+	}
+}
+
+//get-vanilla and get-vanilla-query-order-key-case of the AWS SigV4 test suite
+func TestSignAWSTestSuite(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, exampleSigner().Sign(req, nil))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+
+	req, err = http.NewRequest("GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, exampleSigner().Sign(req, nil))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		req.Header.Get("Authorization"))
+}
+
+func TestSignResignsWithNewTimestamp(t *testing.T) {
+	signer := exampleSigner()
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, signer.Sign(req, nil))
+	first := req.Header.Get("Authorization")
+
+	signer.Now = func() time.Time {
+		return time.Date(2015, 8, 30, 12, 41, 0, 0, time.UTC)
+	}
+	assert.Nil(t, signer.Sign(req, nil))
+	assert.Equal(t, "20150830T124100Z", req.Header.Get("X-Amz-Date"))
+	assert.NotEqual(t, first, req.Header.Get("Authorization"))
+}
+
+func TestSignMissingCredentials(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrMissingCredentials, Signer{Region: "us-east-1", Service: "s3"}.Sign(req, nil))
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//SLOViolationHook is called for every attempt that took longer than the latency
+//SLO declared for its endpoint, regardless of the outcome of the attempt.
+type SLOViolationHook func(req *http.Request, attempt int, elapsed, slo time.Duration)
+
+//latencySLO returns the latency threshold declared for the URL, 0 if there is none.
+//The keys of slos are endpoints in the form host[:port][/path]. The longest key that
+//matches the URL on a path segment boundary wins.
+func latencySLO(slos map[string]time.Duration, u *url.URL) time.Duration {
+	if len(slos) == 0 {
+		return 0
+	}
+	endpoint := u.Host + u.Path
+	longest := -1
+	var result time.Duration
+	for key, slo := range slos {
+		if !matchesEndpoint(endpoint, key) || len(key) <= longest {
+			continue
+		}
+		longest = len(key)
+		result = slo
+	}
+	return result
+}
+
+func matchesEndpoint(endpoint, key string) bool {
+	if !strings.HasPrefix(endpoint, key) {
+		return false
+	}
+	return len(endpoint) == len(key) || strings.HasSuffix(key, "/") || endpoint[len(key)] == '/'
+}
+
+func (c *FailAwareHTTPClient) checkSLO(req *http.Request, attempt int, started, finished time.Time) bool {
+	slo := latencySLO(c.options().LatencySLOs, req.URL)
+	elapsed := finished.Sub(started)
+	if slo <= 0 || elapsed <= slo {
+		return false
+	}
+	c.options().Logger.Debugf("FAH[Debug]: attempt #%d took %dms, SLO is %dms", attempt, elapsed/time.Millisecond, slo/time.Millisecond)
+	if c.options().SLOViolationHook != nil {
+		c.options().SLOViolationHook(req, attempt, elapsed, slo)
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencySLOLongestMatch(t *testing.T) {
+	slos := map[string]time.Duration{
+		"api.example.com":            100 * time.Millisecond,
+		"api.example.com/v1/reports": 10 * time.Second,
+	}
+	cases := map[string]time.Duration{
+		"http://api.example.com/v1/users":          100 * time.Millisecond,
+		"http://api.example.com/v1/reports/2020":   10 * time.Second,
+		"http://api.example.com/v1/reportsarchive": 100 * time.Millisecond,
+		"http://api.example.community/":            0,
+		"http://other.example.com/v1/reports":      0,
+	}
+	for rawURL, expected := range cases {
+		u, err := url.Parse(rawURL)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, latencySLO(slos, u), rawURL)
+	}
+}
+
+func TestSlowSuccessfulAttemptIsFlagged(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	host := fmt.Sprintf("localhost:%d", port)
+
+	var violations []time.Duration
+	opts := NewDefaultOptions()
+	opts.LatencySLOs = map[string]time.Duration{host: 5 * time.Millisecond}
+	opts.SLOViolationHook = func(req *http.Request, attempt int, elapsed, slo time.Duration) {
+		assert.Equal(t, 5*time.Millisecond, slo)
+		violations = append(violations, elapsed)
+	}
+	client := NewClient(opts)
+
+	rsp, err := client.Get("http://" + host + "/slow")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Equal(t, 1, len(violations))
+	assert.True(t, violations[0] >= 20*time.Millisecond)
+}
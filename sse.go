@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//maxReconnectBackOff limits the exponent of the back off between reconnects and failed polls.
+const maxReconnectBackOff = 8
+
+//Event is a server-sent event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+//Subscription delivers the events of a text/event-stream, see Subscribe.
+type Subscription struct {
+	events chan Event
+	err    error
+}
+
+//Events returns the channel of the events. It is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+//Err returns why the subscription ended, once Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+//Subscribe connects to a stream of server-sent events. Dropped connections are reconnected
+//with back off (or the retry time sent by the server), passing the ID of the last event as
+//Last-Event-ID. The subscription ends when ctx is done, the server answers 204 or the
+//request fails with another error than retried ones.
+func (c *FailAwareHTTPClient) Subscribe(ctx context.Context, url string) *Subscription {
+	subscription := &Subscription{events: make(chan Event)}
+	go func() {
+		defer close(subscription.events)
+		subscription.err = c.subscribe(ctx, url, subscription.events)
+	}()
+	return subscription
+}
+
+func (c *FailAwareHTTPClient) subscribe(ctx context.Context, url string, events chan<- Event) error {
+	//the stream has no timeout, it lasts until it drops or ctx is done
+	streamCtx := context.WithValue(ctx, retryPolicyKey, RetryPolicy{Timeout: -1})
+	policyReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	policy := c.retryPolicy(policyReq)
+	stream := &eventStream{}
+	failures := 0
+	for {
+		received, err := c.readStream(streamCtx, url, stream, events)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, errStreamDropped) {
+			return err
+		}
+		if received {
+			failures = 0
+		}
+		wait := stream.retry
+		if wait == 0 {
+			wait = c.backOff(policy, failures)
+		}
+		if failures < maxReconnectBackOff {
+			failures++
+		}
+		c.options().Logger.Debugf("FAH[Debug]: event stream dropped, reconnecting in %dms", wait/time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.options().Clock.After(wait):
+		}
+	}
+}
+
+var errStreamDropped = errors.New("event stream dropped")
+
+//readStream connects and delivers the events of the stream until it ends.
+//Returns whether an event was received and errStreamDropped if it should be reconnected.
+func (c *FailAwareHTTPClient) readStream(ctx context.Context, url string, stream *eventStream, events chan<- Event) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if stream.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", stream.lastEventID)
+	}
+	rsp, err := c.Do(req)
+	if _, failed := retriesFailed(err); failed {
+		return false, errStreamDropped
+	}
+	if err != nil {
+		return false, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNoContent {
+		//the server asks not to reconnect
+		return false, nil
+	}
+	if c.retryPolicy(req).retryable(rsp.StatusCode) {
+		return false, errStreamDropped
+	}
+	if rsp.StatusCode != http.StatusOK || !strings.HasPrefix(rsp.Header.Get("Content-Type"), "text/event-stream") {
+		return false, fmt.Errorf("not an event stream: status %d, content type %q", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+	}
+
+	received := false
+	err = stream.read(rsp.Body, func(event Event) bool {
+		received = true
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		c.options().Logger.Debugf("FAH[Debug]: reading event stream failed: %s", err)
+	}
+	return received, errStreamDropped
+}
+
+//eventStream is the state of a stream that survives reconnects.
+type eventStream struct {
+	lastEventID string
+	retry       time.Duration
+}
+
+//read parses the text/event-stream and dispatches its events until the body ends or
+//dispatch returns false.
+func (s *eventStream) read(body io.Reader, dispatch func(Event) bool) error {
+	reader := bufio.NewReader(body)
+	var event Event
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if data != nil {
+				event.ID = s.lastEventID
+				event.Data = strings.Join(data, "\n")
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				if !dispatch(event) {
+					return nil
+				}
+			}
+			event = Event{}
+			data = nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventStreamRead(t *testing.T) {
+	stream := &eventStream{}
+	var events []Event
+	err := stream.read(strings.NewReader(": comment\r\nretry: 250\r\nid: 7\r\nevent: update\r\ndata: line 1\r\ndata:line 2\r\n\r\ndata\n\nid\n\n"), func(event Event) bool {
+		events = append(events, event)
+		return true
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, []Event{
+		{ID: "7", Event: "update", Data: "line 1\nline 2"},
+		{ID: "7", Event: "message", Data: ""},
+	}, events)
+	assert.Equal(t, "", stream.lastEventID)
+	assert.Equal(t, 250*time.Millisecond, stream.retry)
+}
+
+func TestSubscribeReconnects(t *testing.T) {
+	var lastEventIDs []string
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		switch len(lastEventIDs) {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "retry: 5\nid: 1\ndata: a\n\nid: 2\nevent: update\ndata: b\n\n")
+		case 2:
+			w.WriteHeader(503)
+		case 3:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 3\ndata: c\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 1
+	subscription := NewClient(opts).Subscribe(context.Background(), fmt.Sprintf("http://localhost:%d", port))
+	var events []Event
+	for event := range subscription.Events() {
+		events = append(events, event)
+	}
+	assert.Nil(t, subscription.Err())
+	assert.Equal(t, []Event{
+		{ID: "1", Event: "message", Data: "a"},
+		{ID: "2", Event: "update", Data: "b"},
+		{ID: "3", Event: "message", Data: "c"},
+	}, events)
+	assert.Equal(t, []string{"", "2", "2", "3"}, lastEventIDs)
+}
+
+func TestSubscribeRetryableStatusesOfPolicy(t *testing.T) {
+	requests := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(409)
+		case 2:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 1\ndata: a\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 1
+	opts.MethodPolicies = map[string]RetryPolicy{"GET": {RetryableStatuses: []int{409, 503}}}
+	subscription := NewClient(opts).Subscribe(context.Background(), fmt.Sprintf("http://localhost:%d", port))
+	var events []Event
+	for event := range subscription.Events() {
+		events = append(events, event)
+	}
+	assert.Nil(t, subscription.Err(), "reconnected after the 409")
+	assert.Equal(t, []Event{{ID: "1", Event: "message", Data: "a"}}, events)
+}
+
+func TestSubscribeCanceled(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: a\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription := NewClient(optionsWithMinTimeouts()).Subscribe(ctx, fmt.Sprintf("http://localhost:%d", port))
+	event := <-subscription.Events()
+	assert.Equal(t, "a", event.Data)
+	cancel()
+	for range subscription.Events() {
+	}
+	assert.Equal(t, context.Canceled, subscription.Err())
+}
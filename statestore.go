@@ -0,0 +1,225 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//defaultStateKeyPrefix is the prefix of the keys in the StateStore if StateKeyPrefix is not set.
+const defaultStateKeyPrefix = "failawarehttp:"
+
+//defaultRetryBudgetWindow is the window of a RetryBudget if its Window is not set.
+const defaultRetryBudgetWindow = time.Minute
+
+//ejectionSyncInterval is how often the ejections of other replicas are read from the StateStore.
+const ejectionSyncInterval = time.Second
+
+//memoryStoreSweepInterval is how often the expired entries of a memory StateStore are dropped.
+const memoryStoreSweepInterval = time.Minute
+
+//StateStore keeps the state of the clients that is shared by the replicas of a service, the
+//RetryBudget and the ejections of the OutlierDetection. Implement it with Redis or memcached
+//(e.g. GET, SET with PX, INCRBY and PEXPIRE with NX), so that N replicas do not hammer a
+//recovering backend N times as hard. It must be safe for concurrent use.
+type StateStore interface {
+	//Get returns the value of the key, false if it is not set or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	//Set sets the value of the key, it expires after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	//Increment adds delta to the counter of the key atomically and returns the new count.
+	//A key that is not set starts at 0 and expires after ttl.
+	Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+//memoryStateStore is a StateStore of a single process.
+type memoryStateStore struct {
+	clock   Clock
+	mutex   sync.Mutex
+	entries map[string]memoryEntry
+	swept   time.Time
+}
+
+//NewMemoryStateStore creates a StateStore that keeps the state in memory, e.g. to share it
+//by the clients of one process or in tests.
+func NewMemoryStateStore() StateStore {
+	return newMemoryStateStore(realClock{})
+}
+
+func newMemoryStateStore(clock Clock) *memoryStateStore {
+	return &memoryStateStore{clock: clock, entries: map[string]memoryEntry{}, swept: clock.Now()}
+}
+
+func (s *memoryStateStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !s.clock.Now().Before(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (s *memoryStateStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	s.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *memoryStateStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		entry = memoryEntry{expires: now.Add(ttl)}
+	}
+	var count int64
+	if entry.value != nil {
+		var err error
+		if count, err = strconv.ParseInt(string(entry.value), 10, 64); err != nil {
+			return 0, fmt.Errorf("value of %s is not a counter: %w", key, err)
+		}
+	}
+	count += delta
+	entry.value = []byte(strconv.FormatInt(count, 10))
+	s.entries[key] = entry
+	return count, nil
+}
+
+//sweep drops the expired entries once per memoryStoreSweepInterval, the mutex must be held.
+func (s *memoryStateStore) sweep(now time.Time) {
+	if now.Before(s.swept.Add(memoryStoreSweepInterval)) {
+		return
+	}
+	s.swept = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+//RetryBudget limits the retries to a host within a window. With a StateStore shared by the
+//replicas of a service the budget is shared too: a recovering backend gets at most MaxRetries
+//retries per window from all replicas together instead of from every replica.
+type RetryBudget struct {
+	//MaxRetries to a host within the Window. Requests with a lower priority get only a share
+	//of them, see Priority.
+	MaxRetries int64
+	//Window of the budget, 1 minute if not set. The windows are aligned to the clock, so all
+	//replicas count the same window.
+	Window time.Duration
+}
+
+//stateStore returns the StateStore of the client, its own memory store if not set.
+func (c *FailAwareHTTPClient) stateStore() StateStore {
+	if store := c.options().StateStore; store != nil {
+		return store
+	}
+	return c.localState
+}
+
+//stateKey returns the key of the state in the StateStore.
+func (c *FailAwareHTTPClient) stateKey(parts ...string) string {
+	key := c.options().StateKeyPrefix
+	if key == "" {
+		key = defaultStateKeyPrefix
+	}
+	for i, part := range parts {
+		if i > 0 {
+			key += ":"
+		}
+		key += part
+	}
+	return key
+}
+
+//takeSharedRetryBudget reports whether the RetryBudget allows another retry to the host.
+//The request is retried if the StateStore fails, the budget must not fail requests itself.
+func (c *FailAwareHTTPClient) takeSharedRetryBudget(ctx context.Context, host string) bool {
+	budget := c.options().RetryBudget
+	if budget == nil {
+		return true
+	}
+	window := budget.Window
+	if window <= 0 {
+		window = defaultRetryBudgetWindow
+	}
+	now := c.options().Clock.Now()
+	key := c.stateKey("retries", host, strconv.FormatInt(now.UnixNano()/int64(window), 10))
+	count, err := c.stateStore().Increment(ctx, key, 1, window)
+	if err != nil {
+		c.options().Logger.Debugf("FAH[Debug]: retry budget of %s unavailable, retrying: %s", host, err)
+		return true
+	}
+	return count <= PriorityFromContext(ctx).share(budget.MaxRetries)
+}
+
+//shareEjection stores the ejection of the endpoint for the other replicas.
+func (c *FailAwareHTTPClient) shareEjection(ctx context.Context, host string, until time.Time, ejectionTime time.Duration) {
+	store := c.options().StateStore
+	if store == nil {
+		return
+	}
+	value := []byte(strconv.FormatInt(until.UnixNano(), 10))
+	if err := store.Set(ctx, c.stateKey("ejected", host), value, ejectionTime); err != nil {
+		c.options().Logger.Debugf("FAH[Debug]: sharing the ejection of %s failed: %s", host, err)
+	}
+}
+
+//syncEjections ejects the endpoints that other replicas ejected, at most once per ejectionSyncInterval.
+func (c *FailAwareHTTPClient) syncEjections(ctx context.Context) {
+	store := c.options().StateStore
+	if store == nil || c.options().OutlierDetection == nil {
+		return
+	}
+	pool := c.pool
+	now := c.options().Clock.Now()
+	pool.mutex.Lock()
+	if now.Before(pool.ejectionsSynced.Add(ejectionSyncInterval)) {
+		pool.mutex.Unlock()
+		return
+	}
+	pool.ejectionsSynced = now
+	hosts := make([]string, len(pool.endpoints))
+	for i, endpoint := range pool.endpoints {
+		hosts[i] = endpoint.URL.Host
+	}
+	pool.mutex.Unlock()
+
+	for _, host := range hosts {
+		value, ok, err := store.Get(ctx, c.stateKey("ejected", host))
+		if err != nil {
+			c.options().Logger.Debugf("FAH[Debug]: reading the ejection of %s failed: %s", host, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		untilNanos, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		until := time.Unix(0, untilNanos)
+		pool.mutex.Lock()
+		for _, endpoint := range pool.endpoints {
+			if endpoint.URL.Host == host && endpoint.ejectedUntil.Before(until) && now.Before(until) {
+				endpoint.ejectedUntil = until
+				endpoint.currentWeight = 0
+			}
+		}
+		pool.mutex.Unlock()
+	}
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStateStore(t *testing.T) {
+	clock := newFakeClock()
+	store := newMemoryStateStore(clock)
+	ctx := context.Background()
+
+	_, ok, err := store.Get(ctx, "key")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, store.Set(ctx, "key", []byte("value"), time.Second))
+	value, ok, _ := store.Get(ctx, "key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	count, err := store.Increment(ctx, "counter", 2, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	count, _ = store.Increment(ctx, "counter", 1, time.Minute)
+	assert.Equal(t, int64(3), count, "the ttl applies when the counter is created")
+	_, err = store.Increment(ctx, "key", 1, time.Second)
+	assert.NotNil(t, err)
+
+	clock.now = clock.now.Add(time.Second)
+	_, ok, _ = store.Get(ctx, "key")
+	assert.False(t, ok, "expired")
+	count, _ = store.Increment(ctx, "counter", 1, time.Second)
+	assert.Equal(t, int64(1), count)
+
+	clock.now = clock.now.Add(memoryStoreSweepInterval)
+	assert.Nil(t, store.Set(ctx, "other", nil, time.Second))
+	assert.Equal(t, 1, len(store.entries), "expired entries are dropped")
+}
+
+func TestRetryBudgetSharedByClients(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	opts.ReturnLastResponse = true
+	opts.RetryBudget = &RetryBudget{MaxRetries: 1}
+	opts.StateStore = NewMemoryStateStore()
+
+	rsp, err := NewClient(opts).Get(url)
+	rsp.Body.Close()
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindRetryBudgetExhausted, err.(FailAwareHTTPError).Kind)
+		assert.Equal(t, 1, err.(FailAwareHTTPError).Retries)
+	}
+	rsp, err = NewClient(opts).Get(url)
+	rsp.Body.Close()
+	if assert.IsType(t, FailAwareHTTPError{}, err) {
+		assert.Equal(t, KindRetryBudgetExhausted, err.(FailAwareHTTPError).Kind)
+		assert.Equal(t, 0, err.(FailAwareHTTPError).Retries, "the other client used up the budget")
+	}
+}
+
+func TestEjectionsSharedByClients(t *testing.T) {
+	var hits int32
+	clock := newFakeClock()
+	opts := optionsWithMinTimeouts()
+	opts.Clock = clock
+	opts.Endpoints = []Endpoint{poolServer(t, 200, &hits), poolServer(t, 200, &hits)}
+	opts.OutlierDetection = &OutlierDetection{}
+	opts.StateStore = newMemoryStateStore(clock)
+	ejecting := NewClient(opts)
+	client := NewClient(opts)
+
+	host := opts.Endpoints[0].URL.Host
+	ejecting.shareEjection(context.Background(), host, clock.Now().Add(time.Minute), time.Minute)
+	client.syncEjections(context.Background())
+	assert.Equal(t, clock.Now().Add(time.Minute).UnixNano(), client.pool.endpoints[0].ejectedUntil.UnixNano())
+	assert.True(t, client.pool.endpoints[1].ejectedUntil.IsZero())
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, opts.Endpoints[1].URL.Host, client.pool.pick(clock.Now(), nil).URL.Host)
+	}
+}
+
+func TestSharedRetryBudgetBeforeGroupBudget(t *testing.T) {
+	port, err := serverWith(http.StatusServiceUnavailable)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.RetryBudget = &RetryBudget{MaxRetries: 0}
+	client := NewClient(opts)
+	group := client.NewGroup(context.Background(), GroupOptions{MaxRetries: 1})
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	group.Do(req, nil)
+	assert.Nil(t, group.Wait(), "the 503 is returned")
+	budget := group.Context().Value(retryBudgetKey).(*retryBudget)
+	assert.Equal(t, int64(1), budget.remaining, "the budget of the group is untouched")
+}
+
+func TestTokenRefreshWithinRetryBudget(t *testing.T) {
+	requests := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	opts.RetryBudget = &RetryBudget{MaxRetries: 0}
+	opts.TokenSource = TokenSourceFunc(func(refresh bool) (string, error) {
+		return "token", nil
+	})
+
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.Equal(t, 401, rsp.StatusCode)
+	rsp.Body.Close()
+	assert.Equal(t, 1, requests, "no retry with the refreshed token beyond the budget")
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Stats is a snapshot of the counters of a client, see FailAwareHTTPClient.Stats.
+type Stats struct {
+	//Requests sent by the client, requests answered from a cache are not counted.
+	Requests int64
+	//Attempts of all requests, including the first one.
+	Attempts int64
+	//Retries of all requests, that is all attempts except the first one.
+	Retries int64
+	//RetriedSuccesses requests that succeeded after at least one retry.
+	RetriedSuccesses int64
+	//GiveUps requests that failed, either with an error or with a retryable status after
+	//the last attempt. Canceled requests are counted separately.
+	GiveUps int64
+	//Canceled requests stopped because their context was canceled or its deadline passed.
+	Canceled int64
+	//StatusClasses counts the responses of all attempts by the class of their status,
+	//index 2 are the 2xx responses. Index 0 is unused.
+	StatusClasses [6]int64
+	//Latency of the attempts per host, see FailAwareHTTPClient.Latency.
+	Latency map[string]LatencyPercentiles
+	//FailureRates of the attempts per host, see FailAwareHTTPClient.FailureRate.
+	FailureRates map[string]float64
+}
+
+//clientStats are the counters of a client, they are updated atomically.
+//The statistics per host are guarded by the mutex.
+type clientStats struct {
+	requests         int64
+	attempts         int64
+	retries          int64
+	retriedSuccesses int64
+	giveUps          int64
+	canceled         int64
+	statusClasses    [6]int64
+
+	failureRateWindow time.Duration
+	mutex             sync.Mutex
+	hosts             map[string]*hostStats
+}
+
+type hostStats struct {
+	latencies latencyWindow
+	failures  failureWindow
+}
+
+func newClientStats(failureRateWindow time.Duration) *clientStats {
+	if failureRateWindow < failureRateBuckets {
+		failureRateWindow = defaultFailureRateWindow
+	}
+	return &clientStats{failureRateWindow: failureRateWindow, hosts: map[string]*hostStats{}}
+}
+
+//host returns the statistics of the host, the mutex must be held.
+func (s *clientStats) host(host string) *hostStats {
+	stats, ok := s.hosts[host]
+	if !ok {
+		stats = &hostStats{}
+		s.hosts[host] = stats
+	}
+	return stats
+}
+
+//Stats returns a snapshot of the counters of the client since its creation.
+func (c *FailAwareHTTPClient) Stats() Stats {
+	s := c.stats
+	result := Stats{
+		Requests:         atomic.LoadInt64(&s.requests),
+		Attempts:         atomic.LoadInt64(&s.attempts),
+		Retries:          atomic.LoadInt64(&s.retries),
+		RetriedSuccesses: atomic.LoadInt64(&s.retriedSuccesses),
+		GiveUps:          atomic.LoadInt64(&s.giveUps),
+		Canceled:         atomic.LoadInt64(&s.canceled),
+	}
+	for i := range s.statusClasses {
+		result.StatusClasses[i] = atomic.LoadInt64(&s.statusClasses[i])
+	}
+	bucketID := s.bucketID(c.options().Clock.Now())
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	result.Latency = make(map[string]LatencyPercentiles, len(s.hosts))
+	result.FailureRates = make(map[string]float64, len(s.hosts))
+	for host, stats := range s.hosts {
+		if len(stats.latencies.samples) > 0 {
+			result.Latency[host] = stats.latencies.percentiles()
+		}
+		result.FailureRates[host] = stats.failures.rate(bucketID)
+	}
+	return result
+}
+
+//expvarMutex makes checking for a taken name and publishing atomic.
+var expvarMutex sync.Mutex
+
+//publishExpvar publishes the Stats of the client as expvar. expvar does not allow to
+//replace a published variable, so a taken name is only logged.
+func (c *FailAwareHTTPClient) publishExpvar(name string) {
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+	if expvar.Get(name) != nil {
+		c.options().Logger.Debugf("FAH[Debug]: expvar %s already published, stats of the client not published", name)
+		return
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return c.Stats()
+	}))
+}
+
+//attempt records an attempt, it failed if it got no response or a retryable status.
+func (s *clientStats) attempt(req *http.Request, retried int, rsp *http.Response, failed bool, started, finished time.Time) {
+	atomic.AddInt64(&s.attempts, 1)
+	if retried > 0 {
+		atomic.AddInt64(&s.retries, 1)
+	}
+	if rsp != nil {
+		class := rsp.StatusCode / 100
+		if class > 0 && class < len(s.statusClasses) {
+			atomic.AddInt64(&s.statusClasses[class], 1)
+		}
+	}
+	bucketID := s.bucketID(finished)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	host := s.host(req.URL.Host)
+	host.failures.add(bucketID, failed)
+	if rsp != nil {
+		host.latencies.add(finished.Sub(started))
+	}
+}
+
+func (s *clientStats) finished(policy RetryPolicy, retried int, rsp *http.Response, err error) {
+	atomic.AddInt64(&s.requests, 1)
+	var failErr FailAwareHTTPError
+	switch {
+	case errors.As(err, &failErr) && failErr.Kind == KindCanceled:
+		atomic.AddInt64(&s.canceled, 1)
+	case err != nil || (rsp != nil && policy.retryable(rsp.StatusCode)):
+		atomic.AddInt64(&s.giveUps, 1)
+	case retried > 0:
+		atomic.AddInt64(&s.retriedSuccesses, 1)
+	}
+}
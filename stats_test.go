@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	var hits int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		if r.URL.Path == "/down" {
+			w.WriteHeader(500)
+			return
+		}
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d", port)
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be counted as a failure
+	opts.Timeout = time.Second
+	client := NewClient(opts)
+
+	_, err = client.Get(url + "/ok")
+	assert.Nil(t, err)
+	_, err = client.Get(url + "/flaky")
+	assert.Nil(t, err)
+	_, err = client.Get(url + "/down")
+	assert.Nil(t, err, "the last response is returned")
+	_, err = client.Get(nonExistingURL)
+	assert.NotNil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Do(mustRequestWithContext(t, ctx, url+"/ok"))
+	assert.NotNil(t, err)
+
+	stats := client.Stats()
+	assert.Equal(t, int64(5), stats.Requests)
+	assert.Equal(t, int64(1+2+3+3+1), stats.Attempts)
+	assert.Equal(t, int64(1+2+2), stats.Retries)
+	assert.Equal(t, int64(1), stats.RetriedSuccesses)
+	assert.Equal(t, int64(2), stats.GiveUps)
+	assert.Equal(t, int64(1), stats.Canceled)
+	assert.Equal(t, [6]int64{0, 0, 2, 0, 0, 4}, stats.StatusClasses)
+}
+
+func TestExpvar(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	opts.MaxAttempts = 1
+	opts.ExpvarName = "fah_test_client"
+	client := NewClient(opts)
+	_, err := client.Get(nonExistingURL)
+	assert.NotNil(t, err)
+
+	var stats Stats
+	assert.Nil(t, json.Unmarshal([]byte(expvar.Get("fah_test_client").String()), &stats))
+	assert.Equal(t, int64(1), stats.Requests)
+	assert.Equal(t, int64(1), stats.GiveUps)
+
+	logger := &DummyLogger{}
+	opts.Logger = logger
+	NewClient(opts)
+	assert.Equal(t, 1, len(logger.debugLogs), "a taken name does not panic")
+}
@@ -0,0 +1,26 @@
+package http
+
+import "net/http"
+
+//TokenSource provides the access token sent as bearer token with every attempt.
+//A golang.org/x/oauth2.TokenSource is adapted with TokenSourceFunc, refresh has to
+//bypass the caching of oauth2.ReuseTokenSource.
+type TokenSource interface {
+	//Token returns the current token. If refresh is set, the server rejected the
+	//current token and a new one has to be fetched.
+	Token(refresh bool) (string, error)
+}
+
+//TokenSourceFunc is a function used as TokenSource.
+type TokenSourceFunc func(refresh bool) (string, error)
+
+//Token calls the function.
+func (f TokenSourceFunc) Token(refresh bool) (string, error) {
+	return f(refresh)
+}
+
+//refreshToken reports whether the response rejected the token and it is refreshed
+//for the next attempt. This is done only once per request.
+func (c *FailAwareHTTPClient) refreshToken(rsp *http.Response, refreshed bool) bool {
+	return c.options().TokenSource != nil && !refreshed && rsp != nil && rsp.StatusCode == http.StatusUnauthorized
+}
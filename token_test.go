@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenRefreshOn401(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	refreshes := 0
+	opts := optionsWithMinTimeouts()
+	opts.TokenSource = TokenSourceFunc(func(refresh bool) (string, error) {
+		if refresh {
+			refreshes++
+			return "fresh", nil
+		}
+		return "expired", nil
+	})
+	client := NewClient(opts)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d", port), nil)
+	assert.Nil(t, err)
+	rsp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Equal(t, 1, refreshes)
+	assert.Empty(t, req.Header.Get("Authorization"), "request of the caller unchanged")
+}
+
+func TestTokenRefreshedOnlyOnce(t *testing.T) {
+	port, err := serverWith(http.StatusUnauthorized)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	refreshes := 0
+	opts := optionsWithMinTimeouts()
+	opts.TokenSource = TokenSourceFunc(func(refresh bool) (string, error) {
+		if refresh {
+			refreshes++
+		}
+		return "rejected", nil
+	})
+
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+	assert.Equal(t, 1, refreshes)
+}
+
+func TestTokenError(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	tokenErr := errors.New("token endpoint down")
+	opts := optionsWithMinTimeouts()
+	opts.TokenSource = TokenSourceFunc(func(refresh bool) (string, error) {
+		return "", tokenErr
+	})
+
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.True(t, errors.Is(failErr.LastError, tokenErr))
+}
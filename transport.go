@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+//newTransport creates the transport of a client that has no Transport in its options.
+//It is an own transport, closing its connections does not affect other clients.
+func newTransport(options FailAwareHTTPOptions) (*http.Transport, *dnsCache) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(options)
+	if options.TLSConfig != nil {
+		transport.TLSClientConfig = options.TLSConfig.Clone()
+	}
+	if options.MaxIdleConns != 0 {
+		transport.MaxIdleConns = options.MaxIdleConns
+	}
+	if options.MaxIdleConnsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+	if options.MaxConnsPerHost != 0 {
+		transport.MaxConnsPerHost = options.MaxConnsPerHost
+	}
+	if options.IdleConnTimeout != 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	if options.DialTimeout != 0 {
+		//same keep alive as http.DefaultTransport
+		transport.DialContext = (&net.Dialer{Timeout: options.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if options.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = options.TLSHandshakeTimeout
+	}
+	if options.ResponseHeaderTimeout != 0 {
+		transport.ResponseHeaderTimeout = options.ResponseHeaderTimeout
+	}
+
+	var dnsCache *dnsCache
+	if options.DNSCacheTTL > 0 {
+		dnsCache = newDNSCache(options.DNSCacheTTL, options.Clock)
+		transport.DialContext = dnsCache.dialContext(transport.DialContext)
+	}
+	if options.DualStackFallback {
+		transport.DialContext = dualStackDial(transport.DialContext)
+	}
+	transport.DialContext = unixDial(options.UnixSocket, transport.DialContext)
+	transport.RegisterProtocol(unixScheme, unixRoundTripper(transport))
+	if options.H2C {
+		enableH2C(transport, options.Logger)
+	}
+	return transport, dnsCache
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutualTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxRetries = 1
+	opts.Timeout = time.Second //enough for the TLS handshake on a busy machine
+	opts.TLSConfig = &tls.Config{RootCAs: roots}
+	_, err := NewClient(opts).Get(server.URL)
+	assert.NotNil(t, err, "client certificate required")
+
+	opts.TLSConfig = &tls.Config{
+		RootCAs:      roots,
+		Certificates: server.TLS.Certificates,
+		MinVersion:   tls.VersionTLS12,
+	}
+	rsp, err := NewClient(opts).Get(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+}
+
+func TestConnectionPoolOptions(t *testing.T) {
+	opts := optionsWithMinTimeouts()
+	transport, _ := newTransport(opts)
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	assert.Equal(t, defaultTransport.MaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaultTransport.IdleConnTimeout, transport.IdleConnTimeout)
+
+	opts.MaxIdleConns = 500
+	opts.MaxIdleConnsPerHost = 100
+	opts.MaxConnsPerHost = 200
+	opts.IdleConnTimeout = 30 * time.Second
+	transport, _ = newTransport(opts)
+	assert.Equal(t, 500, transport.MaxIdleConns)
+	assert.Equal(t, 100, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 200, transport.MaxConnsPerHost)
+	assert.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+}
+
+func TestPhaseTimeouts(t *testing.T) {
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+
+	opts := optionsWithMinTimeouts()
+	opts.MaxAttempts = 1
+	opts.Timeout = 5 * time.Second
+	opts.DialTimeout = time.Second
+	opts.TLSHandshakeTimeout = 2 * time.Second
+	opts.ResponseHeaderTimeout = 20 * time.Millisecond
+	transport, _ := newTransport(opts)
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, 20*time.Millisecond, transport.ResponseHeaderTimeout)
+
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	failErr, ok := err.(FailAwareHTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, ClassTimeout, ClassifyError(failErr.LastError), "the response headers took too long")
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//unixScheme is the scheme of URLs that address a Unix domain socket. The path of the
+//socket is followed by a colon and the path of the request, e.g.
+//unix:///var/run/app.sock:/v1/status. Without a request path "/" is requested.
+const unixScheme = "unix"
+
+//unixDial dials the Unix socket of the request (see unixRoundTripper) or the socket preset
+//for the client. All other dials are passed to dial.
+func unixDial(socket string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if path, ok := ctx.Value(unixSocketKey).(string); ok {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		if socket != "" {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+//splitUnixPath splits the path of a unix URL into the path of the socket and of the request.
+func splitUnixPath(path string) (string, string) {
+	i := strings.Index(path, ":")
+	if i < 0 {
+		return path, "/"
+	}
+	return path[:i], path[i+1:]
+}
+
+//unixRoundTripper sends requests to unix URLs as plain HTTP requests over the socket.
+func unixRoundTripper(transport *http.Transport) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		socket, path := splitUnixPath(req.URL.Path)
+		//a host per socket keeps the connection pools of the sockets apart
+		hash := sha1.Sum([]byte(socket))
+		unixReq := req.Clone(context.WithValue(req.Context(), unixSocketKey, socket))
+		unixReq.URL = &url.URL{
+			Scheme:   "http",
+			Host:     "unix-" + hex.EncodeToString(hash[:8]),
+			Path:     path,
+			RawQuery: req.URL.RawQuery,
+		}
+		unixReq.Host = "localhost"
+		return transport.RoundTrip(unixReq)
+	})
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func unixServer(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "fah")
+	if err != nil {
+		t.Fatal("unable to create dir", err)
+	}
+	socket := filepath.Join(dir, "app.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal("unable to listen", err)
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.RequestURI()))
+	}))
+	return socket, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUnixSocketPreset(t *testing.T) {
+	socket, stop := unixServer(t)
+	defer stop()
+
+	opts := NewDefaultOptions()
+	opts.UnixSocket = socket
+	client := NewClient(opts)
+
+	rsp, err := client.Get("http://app/v1/status")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "app/v1/status", string(body))
+}
+
+func TestUnixSocketURL(t *testing.T) {
+	socket, stop := unixServer(t)
+	defer stop()
+
+	client := NewDefaultClient()
+	rsp, err := client.Get("unix://" + socket + ":/v1/status?verbose=1")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost/v1/status?verbose=1", string(body))
+}
+
+func TestSplitUnixPath(t *testing.T) {
+	socket, path := splitUnixPath("/var/run/app.sock:/v1/status")
+	assert.Equal(t, "/var/run/app.sock", socket)
+	assert.Equal(t, "/v1/status", path)
+
+	socket, path = splitUnixPath("/var/run/app.sock")
+	assert.Equal(t, "/var/run/app.sock", socket)
+	assert.Equal(t, "/", path)
+}
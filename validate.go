@@ -0,0 +1,125 @@
+package http
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//busyLoopBackOff is the back off below which retrying many times hammers the server.
+const busyLoopBackOff = time.Millisecond
+
+//busyLoopAttempts is the number of attempts beyond which a back off below busyLoopBackOff is rejected.
+const busyLoopAttempts = 10
+
+//InvalidOptionsError lists the problems of options rejected by Validate.
+type InvalidOptionsError struct {
+	Problems []string
+}
+
+func (e InvalidOptionsError) Error() string {
+	return "invalid options: " + strings.Join(e.Problems, "; ")
+}
+
+//NewClientE creates a new client like NewClient, but rejects nonsensical options with an
+//InvalidOptionsError instead of silently substituting defaults, see Validate.
+func NewClientE(options FailAwareHTTPOptions) (*FailAwareHTTPClient, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return NewClient(options), nil
+}
+
+//Validate checks the options for nonsensical settings: negative retries or back offs, a
+//MaxBackOff below the BackOffDelayFactor, a back off below a millisecond with more than
+//10 attempts (which hammers the server), JitterFull with DisableJitter and unknown back off
+//strategies, jitter modes or log levels. The policies per method and host are checked
+//together with the options they override. Unset options are valid, they get their defaults.
+func (o FailAwareHTTPOptions) Validate() error {
+	options := withDefaults(o)
+	clientPolicy := options.retryPolicy()
+	problems := clientPolicy.problems()
+	if options.MaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("MaxRetries %d is negative", options.MaxRetries))
+	}
+	if _, err := logrus.ParseLevel(o.LogLevel); o.LogLevel != "" && err != nil {
+		problems = append(problems, fmt.Sprintf("unknown LogLevel %q", o.LogLevel))
+	}
+	if options.MaxResponseBytes < 0 {
+		problems = append(problems, fmt.Sprintf("MaxResponseBytes %d is negative", options.MaxResponseBytes))
+	}
+	if options.MaxConcurrentRequests < 0 {
+		problems = append(problems, fmt.Sprintf("MaxConcurrentRequests %d is negative", options.MaxConcurrentRequests))
+	}
+	if options.MaxConcurrentRetries < 0 {
+		problems = append(problems, fmt.Sprintf("MaxConcurrentRetries %d is negative", options.MaxConcurrentRetries))
+	}
+	if options.RetryBudget != nil && options.RetryBudget.MaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("RetryBudget.MaxRetries %d is negative", options.RetryBudget.MaxRetries))
+	}
+	if options.RetryBudget != nil && options.RetryBudget.Window < 0 {
+		problems = append(problems, fmt.Sprintf("RetryBudget.Window %s is negative", options.RetryBudget.Window))
+	}
+	if options.MaxQueueWait < 0 {
+		problems = append(problems, fmt.Sprintf("MaxQueueWait %s is negative", options.MaxQueueWait))
+	}
+	problems = append(problems, policyProblems("MethodPolicies", options.MethodPolicies, clientPolicy)...)
+	problems = append(problems, policyProblems("HostPolicies", options.HostPolicies, clientPolicy)...)
+	if len(problems) > 0 {
+		return InvalidOptionsError{Problems: problems}
+	}
+	return nil
+}
+
+func policyProblems(name string, policies map[string]RetryPolicy, clientPolicy RetryPolicy) []string {
+	keys := make([]string, 0, len(policies))
+	for key := range policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var problems []string
+	for _, key := range keys {
+		policy := policies[key]
+		if policy.MaxRetries < 0 {
+			problems = append(problems, fmt.Sprintf("%s[%s]: MaxRetries %d is negative", name, key, policy.MaxRetries))
+		}
+		for _, problem := range policy.withDefaults(clientPolicy).problems() {
+			problems = append(problems, fmt.Sprintf("%s[%s]: %s", name, key, problem))
+		}
+	}
+	return problems
+}
+
+//problems returns the nonsensical settings of the policy.
+func (p RetryPolicy) problems() []string {
+	var problems []string
+	if p.MaxAttempts < 0 && p.MaxAttempts != RetryForever {
+		problems = append(problems, fmt.Sprintf("MaxAttempts %d is negative but not RetryForever", p.MaxAttempts))
+	}
+	if p.BackOffDelayFactor < 0 {
+		problems = append(problems, fmt.Sprintf("BackOffDelayFactor %s is negative", p.BackOffDelayFactor))
+	}
+	if p.MaxBackOff < 0 {
+		problems = append(problems, fmt.Sprintf("MaxBackOff %s is negative", p.MaxBackOff))
+	}
+	if p.MaxBackOff > 0 && p.MaxBackOff < p.BackOffDelayFactor {
+		problems = append(problems, fmt.Sprintf("MaxBackOff %s is below the BackOffDelayFactor %s", p.MaxBackOff, p.BackOffDelayFactor))
+	}
+	attempts := p.attempts()
+	if p.BackOffDelayFactor < busyLoopBackOff && (attempts == RetryForever || attempts > busyLoopAttempts) {
+		problems = append(problems, fmt.Sprintf("BackOffDelayFactor %s is below %s with %d attempts", p.BackOffDelayFactor, busyLoopBackOff, attempts))
+	}
+	if p.DisableJitter && p.Jitter == JitterFull {
+		problems = append(problems, "Jitter JitterFull conflicts with DisableJitter")
+	}
+	if p.BackOffStrategy < BackOffExponential || p.BackOffStrategy > BackOffFibonacci {
+		problems = append(problems, fmt.Sprintf("unknown BackOffStrategy %d", p.BackOffStrategy))
+	}
+	if p.Jitter < JitterRelative || p.Jitter > JitterFull {
+		problems = append(problems, fmt.Sprintf("unknown Jitter %d", p.Jitter))
+	}
+	return problems
+}
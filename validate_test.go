@@ -0,0 +1,67 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClientE(t *testing.T) {
+	client, err := NewClientE(FailAwareHTTPOptions{})
+	assert.Nil(t, err)
+	assert.NotNil(t, client)
+	_, err = NewClientE(optionsWithMinTimeouts())
+	assert.Nil(t, err)
+
+	client, err = NewClientE(FailAwareHTTPOptions{MaxRetries: -1})
+	assert.Nil(t, client)
+	assert.Equal(t, InvalidOptionsError{Problems: []string{"MaxRetries -1 is negative"}}, err)
+}
+
+func TestValidate(t *testing.T) {
+	for name, test := range map[string]struct {
+		options  FailAwareHTTPOptions
+		problems []string
+	}{
+		"negative attempts": {
+			options:  FailAwareHTTPOptions{MaxAttempts: -2},
+			problems: []string{"MaxAttempts -2 is negative but not RetryForever"},
+		},
+		"negative back off": {
+			options:  FailAwareHTTPOptions{BackOffDelayFactor: -time.Second, MaxBackOff: -time.Second},
+			problems: []string{"BackOffDelayFactor -1s is negative", "MaxBackOff -1s is negative"},
+		},
+		"max back off below factor": {
+			options:  FailAwareHTTPOptions{BackOffDelayFactor: time.Second, MaxBackOff: time.Millisecond},
+			problems: []string{"MaxBackOff 1ms is below the BackOffDelayFactor 1s"},
+		},
+		"busy loop": {
+			options:  FailAwareHTTPOptions{BackOffDelayFactor: time.Microsecond, MaxAttempts: RetryForever},
+			problems: []string{"BackOffDelayFactor 1µs is below 1ms with -1 attempts"},
+		},
+		"conflicting jitter": {
+			options:  FailAwareHTTPOptions{DisableJitter: true, Jitter: JitterFull},
+			problems: []string{"Jitter JitterFull conflicts with DisableJitter"},
+		},
+		"unknown strategy": {
+			options:  FailAwareHTTPOptions{BackOffStrategy: 7, MaxResponseBytes: -1, LogLevel: "verbose"},
+			problems: []string{"unknown BackOffStrategy 7", "unknown LogLevel \"verbose\"", "MaxResponseBytes -1 is negative"},
+		},
+		"policies": {
+			options: FailAwareHTTPOptions{
+				DisableJitter:  true,
+				MethodPolicies: map[string]RetryPolicy{"GET": {MaxAttempts: 50, BackOffDelayFactor: time.Nanosecond}},
+				HostPolicies:   map[string]RetryPolicy{"b": {Jitter: JitterFull}, "a": {MaxRetries: -3}},
+			},
+			problems: []string{
+				"MethodPolicies[GET]: BackOffDelayFactor 1ns is below 1ms with 50 attempts",
+				"HostPolicies[a]: MaxRetries -3 is negative",
+				"HostPolicies[b]: Jitter JitterFull conflicts with DisableJitter",
+			},
+		},
+	} {
+		assert.Equal(t, InvalidOptionsError{Problems: test.problems}, test.options.Validate(), name)
+	}
+	assert.Nil(t, FailAwareHTTPOptions{MaxAttempts: RetryForever, MaxBackOff: time.Minute}.Validate())
+}
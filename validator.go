@@ -0,0 +1,50 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+//ResponseValidator checks a response on the application level, e.g. an upstream that answers
+//with 200 and {"status":"error"}. A non-nil error fails the attempt, it is retried if the error
+//is an InvalidResponseError with Retryable set. See FailAwareHTTPOptions.ValidateResponse.
+type ResponseValidator func(rsp *http.Response) error
+
+//InvalidResponseError is the error of an attempt whose response was rejected by the
+//ResponseValidator. Errors of the validator that are no InvalidResponseError are wrapped
+//into one that is not retryable.
+type InvalidResponseError struct {
+	Err       error
+	Retryable bool
+}
+
+func (e InvalidResponseError) Error() string {
+	return "invalid response: " + e.Err.Error()
+}
+
+func (e InvalidResponseError) Unwrap() error {
+	return e.Err
+}
+
+//validateResponse calls the ResponseValidator with the response. The body is read before,
+//so the validator and the caller can both read it.
+func (c *FailAwareHTTPClient) validateResponse(rsp *http.Response) error {
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	err = c.options().ValidateResponse(rsp)
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err == nil {
+		return nil
+	}
+	var invalid InvalidResponseError
+	if !errors.As(err, &invalid) {
+		return InvalidResponseError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type upstreamStatus struct {
+	Status    string `json:"status"`
+	Retryable bool   `json:"retryable"`
+}
+
+func validateUpstreamStatus(rsp *http.Response) error {
+	var status upstreamStatus
+	if err := json.NewDecoder(rsp.Body).Decode(&status); err != nil {
+		return err
+	}
+	if status.Status != "error" {
+		return nil
+	}
+	return InvalidResponseError{Err: errors.New("upstream error"), Retryable: status.Retryable}
+}
+
+func TestValidateResponse(t *testing.T) {
+	var requests int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			fmt.Fprint(w, `{"status":"error","retryable":true}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would use up an attempt
+	opts.Timeout = time.Second
+	opts.ValidateResponse = validateUpstreamStatus
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	body, _ := ioutil.ReadAll(rsp.Body)
+	assert.Equal(t, `{"status":"ok"}`, string(body), "the body can be read after the validation")
+}
+
+func TestValidateResponseNotRetryable(t *testing.T) {
+	var requests int32
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"status":"error","retryable":false}`)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	opts := optionsWithMinTimeouts()
+	//an attempt timing out on a slow machine (e.g. with -race) would be retried
+	opts.Timeout = time.Second
+	opts.ValidateResponse = validateUpstreamStatus
+	rsp, err := NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 200, rsp.StatusCode)
+	failErr := err.(FailAwareHTTPError)
+	assert.Equal(t, KindNotRetryable, failErr.Kind)
+	assert.Equal(t, ReasonInvalidResponse, failErr.Errors[0].Reason())
+
+	opts.ValidateResponse = func(rsp *http.Response) error {
+		return errors.New("unexpected content type")
+	}
+	_, err = NewClient(opts).Get(fmt.Sprintf("http://localhost:%d", port))
+	var invalid InvalidResponseError
+	assert.True(t, errors.As(err.(FailAwareHTTPError).LastError, &invalid))
+	assert.False(t, invalid.Retryable)
+	assert.Equal(t, "invalid response: unexpected content type", invalid.Error())
+}
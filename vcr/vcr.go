@@ -0,0 +1,226 @@
+//Package vcr records the attempts of the fail-aware client to a cassette file and
+//replays them in tests, so a flaky backend scenario has to be captured only once.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+)
+
+//Mode selects whether a Recorder records or replays.
+type Mode int
+
+const (
+	//ModeRecord sends the attempts with the real transport and records them.
+	ModeRecord Mode = iota
+	//ModeReplay answers the attempts from the cassette, without network access.
+	ModeReplay
+)
+
+//ErrNoInteraction is returned while replaying if the cassette has no (more) interaction
+//for a request.
+var ErrNoInteraction = errors.New("vcr: no recorded interaction for request")
+
+//RecordedRequest is the recorded part of a request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+//RecordedResponse is the recorded part of a response.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+//Interaction is a single recorded attempt. Attempt is the number of the attempt
+//of the client (starting at 0), -1 if the request was not sent by the fail-aware client.
+type Interaction struct {
+	Attempt  int               `json:"attempt"`
+	Request  RecordedRequest   `json:"request"`
+	Response *RecordedResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+//Cassette is the list of recorded interactions in the order of recording.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+//Load reads a cassette from a file.
+func Load(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("vcr: invalid cassette %s: %v", path, err)
+	}
+	return &cassette, nil
+}
+
+//Save writes the cassette to a file.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+//redactedHeaders are redacted in the cassette in addition to the ones the client always
+//redacts, see failawarehttp.RedactHeader.
+var redactedHeaders = []string{"X-Amz-Security-Token"}
+
+//Recorder is an http.RoundTripper that records or replays attempts. Use it as
+//FailAwareHTTPOptions.Transport.
+type Recorder struct {
+	//RedactHeaders are redacted in the cassette in addition to the credentials and cookies,
+	//set them before the first request.
+	RedactHeaders []string
+
+	mode      Mode
+	path      string
+	transport http.RoundTripper
+
+	mutex    sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+//New creates a Recorder for the cassette at path. In ModeRecord the attempts are sent
+//with transport (http.DefaultTransport if nil) and the cassette is written on Stop.
+//In ModeReplay the cassette is loaded from path.
+func New(path string, mode Mode, transport http.RoundTripper) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	recorder := &Recorder{mode: mode, path: path, transport: transport, cassette: &Cassette{}}
+	if mode == ModeReplay {
+		cassette, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		recorder.cassette = cassette
+		recorder.used = make([]bool, len(cassette.Interactions))
+	}
+	return recorder, nil
+}
+
+//RoundTrip records or replays a single attempt.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recordedReq, err := recordRequest(req, r.redactedHeaders())
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, recordedReq)
+	}
+
+	attempt, ok := failawarehttp.AttemptFromContext(req.Context())
+	if !ok {
+		attempt = -1
+	}
+	interaction := Interaction{Attempt: attempt, Request: recordedReq}
+	rsp, rspErr := r.transport.RoundTrip(req)
+	if rspErr != nil {
+		interaction.Error = rspErr.Error()
+	} else {
+		interaction.Response, err = recordResponse(rsp, r.redactedHeaders())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.mutex.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mutex.Unlock()
+	if rspErr != nil {
+		//the original error, which is classified by the client
+		return nil, rspErr
+	}
+	return rsp, nil
+}
+
+func (r *Recorder) redactedHeaders() []string {
+	return append(append([]string(nil), redactedHeaders...), r.RedactHeaders...)
+}
+
+func (r *Recorder) replay(req *http.Request, recordedReq RecordedRequest) (*http.Response, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.Request.Method != recordedReq.Method || interaction.Request.URL != recordedReq.URL {
+			continue
+		}
+		r.used[i] = true
+		if interaction.Error != "" {
+			return nil, errors.New(interaction.Error)
+		}
+		return &http.Response{
+			Status:        http.StatusText(interaction.Response.StatusCode),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header,
+			Body:          ioutil.NopCloser(bytes.NewBufferString(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recordedReq.Method, recordedReq.URL)
+}
+
+//Cassette returns the interactions recorded or loaded so far.
+func (r *Recorder) Cassette() Cassette {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+//Stop writes the cassette to disk if recording. It does nothing when replaying.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+func recordRequest(req *http.Request, redacted []string) (RecordedRequest, error) {
+	recorded := RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: failawarehttp.RedactHeader(req.Header, redacted...)}
+	if req.Body == nil {
+		return recorded, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	recorded.Body = string(body)
+	return recorded, nil
+}
+
+func recordResponse(rsp *http.Response, redacted []string) (*RecordedResponse, error) {
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return &RecordedResponse{StatusCode: rsp.StatusCode, Header: failawarehttp.RedactHeader(rsp.Header, redacted...), Body: string(body)}, nil
+}
@@ -0,0 +1,92 @@
+package vcr
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	failawarehttp "github.com/Ragnaroek/failawarehttp"
+	"github.com/Ragnaroek/failawarehttp/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func clientWith(transport *Recorder) *failawarehttp.FailAwareHTTPClient {
+	return failawarehttp.NewClient(failawarehttp.FailAwareHTTPOptions{
+		Transport: transport,
+		Clock:     fake.NewClock(time.Now()),
+	})
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcr")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flaky.json")
+
+	backend := fake.NewTransport(fake.Error(errors.New("connection reset")), fake.Status(503), fake.Step{StatusCode: 200, Body: "ok"})
+	recorder, err := New(path, ModeRecord, backend)
+	assert.Nil(t, err)
+	rsp, err := clientWith(recorder).Get("http://example.com/flaky")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	assert.Nil(t, recorder.Stop())
+
+	cassette, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(cassette.Interactions))
+	for i, interaction := range cassette.Interactions {
+		assert.Equal(t, i, interaction.Attempt)
+	}
+	assert.Equal(t, "connection reset", cassette.Interactions[0].Error)
+	assert.Equal(t, 503, cassette.Interactions[1].Response.StatusCode)
+
+	replayer, err := New(path, ModeReplay, nil)
+	assert.Nil(t, err)
+	rsp, err = clientWith(replayer).Get("http://example.com/flaky")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(body))
+
+	_, err = replayer.RoundTrip(rsp.Request)
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+}
+
+func TestRecordRedactsHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcr")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	reset := errors.New("connection reset")
+	backend := fake.NewTransport(fake.Error(reset), fake.Step{StatusCode: 200, Header: http.Header{"Set-Cookie": {"session=secret"}, "Etag": {`"v1"`}}})
+	recorder, err := New(filepath.Join(dir, "redacted.json"), ModeRecord, backend)
+	assert.Nil(t, err)
+	recorder.RedactHeaders = []string{"X-Api-Key"}
+	req, err := http.NewRequest("GET", "http://example.com/secret", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Amz-Security-Token", "token")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("Accept", "text/plain")
+
+	_, err = recorder.RoundTrip(req)
+	assert.True(t, errors.Is(err, reset), "the original error")
+	rsp, err := recorder.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "session=secret", rsp.Header.Get("Set-Cookie"), "the response is not redacted")
+
+	interactions := recorder.Cassette().Interactions
+	header := interactions[0].Request.Header
+	assert.Equal(t, "REDACTED", header.Get("Authorization"))
+	assert.Equal(t, "REDACTED", header.Get("X-Amz-Security-Token"))
+	assert.Equal(t, "REDACTED", header.Get("X-Api-Key"))
+	assert.Equal(t, "text/plain", header.Get("Accept"))
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"), "the request is not redacted")
+	assert.Equal(t, "REDACTED", interactions[1].Response.Header.Get("Set-Cookie"))
+	assert.Equal(t, `"v1"`, interactions[1].Response.Header.Get("Etag"))
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//websocketGUID is the GUID of the Sec-WebSocket-Accept computation (RFC 6455 1.3).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+//ErrHandshake is returned if the server does not upgrade to a WebSocket.
+var ErrHandshake = errors.New("websocket handshake failed")
+
+//WebSocketOptions configure DialWebSocket.
+type WebSocketOptions struct {
+	//Header is sent with the handshake.
+	Header http.Header
+	//Protocols are offered as Sec-WebSocket-Protocol.
+	Protocols []string
+	//OnReconnect is called before every reconnect with the number of the reconnect since the
+	//last successful handshake (starting at 1) and the error that caused it.
+	OnReconnect func(reconnect int, cause error)
+}
+
+//WebSocket is a WebSocket connection that is reconnected with the back off and retry
+//budget of the client. The frames of the connection are not handled, use Conn with a
+//WebSocket implementation.
+type WebSocket struct {
+	client  *FailAwareHTTPClient
+	ctx     context.Context
+	url     string
+	options WebSocketOptions
+
+	mutex    sync.Mutex
+	conn     io.ReadWriteCloser
+	protocol string
+
+	//reconnecting serializes the reconnects, which wait without holding mutex
+	reconnecting sync.Mutex
+	reconnects   int
+}
+
+//DialWebSocket connects to a ws:// or wss:// URL. The handshake is retried like any
+//other request of the client.
+func (c *FailAwareHTTPClient) DialWebSocket(ctx context.Context, url string, options WebSocketOptions) (*WebSocket, error) {
+	ws := &WebSocket{client: c, ctx: ctx, url: url, options: options}
+	conn, protocol, err := ws.handshake()
+	if err != nil {
+		return nil, err
+	}
+	ws.conn = conn
+	ws.protocol = protocol
+	return ws, nil
+}
+
+//Conn returns the current connection.
+func (w *WebSocket) Conn() io.ReadWriteCloser {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.conn
+}
+
+//Protocol returns the subprotocol selected by the server.
+func (w *WebSocket) Protocol() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.protocol
+}
+
+//Reconnect replaces the connection after it failed with cause. It backs off like a retry
+//and takes from the retry budget of the context of the dial, see RequestGroup. The back off
+//starts again after a successful reconnect.
+func (w *WebSocket) Reconnect(cause error) error {
+	w.reconnecting.Lock()
+	defer w.reconnecting.Unlock()
+	w.Conn().Close()
+	if !takeRetryBudget(w.ctx) {
+		return FailAwareHTTPError{Kind: KindRetryBudgetExhausted, Retries: w.reconnects, LastError: cause}
+	}
+	w.reconnects++
+	if w.options.OnReconnect != nil {
+		w.options.OnReconnect(w.reconnects, cause)
+	}
+
+	policyReq, err := http.NewRequest("GET", httpURL(w.url), nil)
+	if err != nil {
+		return err
+	}
+	wait := w.client.backOff(w.client.retryPolicy(policyReq), w.reconnects-1)
+	w.client.options().Logger.Debugf("FAH[Debug]: reconnecting websocket in %dms: %s", wait/time.Millisecond, cause)
+	select {
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	case <-w.client.options().Clock.After(wait):
+	}
+
+	conn, protocol, err := w.handshake()
+	if err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	w.conn = conn
+	w.protocol = protocol
+	w.mutex.Unlock()
+	w.reconnects = 0
+	return nil
+}
+
+//Close closes the current connection.
+func (w *WebSocket) Close() error {
+	return w.Conn().Close()
+}
+
+func (w *WebSocket) handshake() (io.ReadWriteCloser, string, error) {
+	req, err := http.NewRequestWithContext(w.ctx, "GET", httpURL(w.url), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for name, values := range w.options.Header {
+		req.Header[name] = values
+	}
+	key, err := websocketKey()
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if len(w.options.Protocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(w.options.Protocols, ", "))
+	}
+
+	rsp, err := w.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		closeBody(rsp)
+		return nil, "", fmt.Errorf("%w: status %d", ErrHandshake, rsp.StatusCode)
+	}
+	body := rsp.Body
+	//the body of the attempt is wrapped for the request and the attempt
+	for {
+		wrapped, ok := body.(*cancelOnClose)
+		if !ok {
+			break
+		}
+		body = wrapped.ReadCloser
+	}
+	conn, ok := body.(io.ReadWriteCloser)
+	if !ok || rsp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		rsp.Body.Close()
+		return nil, "", fmt.Errorf("%w: invalid upgrade response", ErrHandshake)
+	}
+	return &cancelOnCloseConn{ReadWriteCloser: conn, body: rsp.Body}, rsp.Header.Get("Sec-WebSocket-Protocol"), nil
+}
+
+//cancelOnCloseConn closes the body of the upgrade response, which releases its attempt.
+type cancelOnCloseConn struct {
+	io.ReadWriteCloser
+	body io.Closer
+}
+
+func (c *cancelOnCloseConn) Close() error {
+	return c.body.Close()
+}
+
+//httpURL returns the http(s) URL of a ws(s) URL.
+func httpURL(url string) string {
+	if strings.HasPrefix(url, "ws://") {
+		return "http://" + strings.TrimPrefix(url, "ws://")
+	}
+	if strings.HasPrefix(url, "wss://") {
+		return "https://" + strings.TrimPrefix(url, "wss://")
+	}
+	return url
+}
+
+func websocketKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
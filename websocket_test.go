@@ -0,0 +1,87 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//echoWebSocketServer upgrades every handshake after the first failed ones and echoes raw bytes
+func echoWebSocketServer(t *testing.T, failed int) (int, *int) {
+	handshakes := 0
+	port, err := serverWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		handshakes++
+		if handshakes <= failed {
+			w.WriteHeader(503)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\nSec-WebSocket-Protocol: chat\r\n\r\n", websocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+		buf.Flush()
+		io.Copy(conn, buf)
+	})
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	return port, &handshakes
+}
+
+func TestDialWebSocket(t *testing.T) {
+	port, handshakes := echoWebSocketServer(t, 1)
+	opts := optionsWithMinTimeouts()
+	opts.Timeout = 20 * time.Millisecond
+	client := NewClient(opts)
+
+	ws, err := client.DialWebSocket(context.Background(), fmt.Sprintf("ws://localhost:%d/chat", port), WebSocketOptions{Protocols: []string{"chat"}})
+	assert.Nil(t, err)
+	defer ws.Close()
+	assert.Equal(t, 2, *handshakes)
+	assert.Equal(t, "chat", ws.Protocol())
+
+	//the connection outlives the timeout of the handshake attempt
+	time.Sleep(50 * time.Millisecond)
+	_, err = ws.Conn().Write([]byte("ping\n"))
+	assert.Nil(t, err)
+	line, err := bufio.NewReader(ws.Conn()).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "ping\n", line)
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	port, handshakes := echoWebSocketServer(t, 0)
+	var reconnects []int
+	client := NewClient(optionsWithMinTimeouts())
+	ws, err := client.DialWebSocket(context.Background(), fmt.Sprintf("ws://localhost:%d", port), WebSocketOptions{
+		OnReconnect: func(reconnect int, cause error) {
+			reconnects = append(reconnects, reconnect)
+		},
+	})
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	assert.Nil(t, ws.Reconnect(io.EOF))
+	assert.Nil(t, ws.Reconnect(io.EOF))
+	assert.Equal(t, []int{1, 1}, reconnects, "counted from the last successful handshake")
+	assert.Equal(t, 3, *handshakes)
+}
+
+func TestDialWebSocketHandshakeFailed(t *testing.T) {
+	port, err := serverWith(200)
+	if err != nil {
+		t.Fatal("unable to start server", err)
+	}
+	_, err = NewClient(optionsWithMinTimeouts()).DialWebSocket(context.Background(), fmt.Sprintf("ws://localhost:%d", port), WebSocketOptions{})
+	assert.True(t, errors.Is(err, ErrHandshake))
+}